@@ -33,35 +33,248 @@ type Processor struct {
 	Related Related `json:"related,omitempty"`
 }
 
+// External references ConfigMaps holding files used by a test script, e.g. CSV payloads or JS processors.
+// Their keys are mounted alongside the test script in the /data directory of every worker.
 type External struct {
 	Payload   *Payload   `json:"payload,omitempty"`
 	Processor *Processor `json:"processor,omitempty"`
 }
 
 type Config struct {
-	// +kubebuilder:validation:MinLength=1
-	// +kubebuilder:validation:Required
-	ConfigMap string `json:"configMap"`
+	// ConfigMap holding the test script.
+	// Required unless the test script is set inline using spec.testScript.inline.
+	// +optional
+	ConfigMap string `json:"configMap,omitempty"`
+
+	// Key of the ConfigMap entry holding the test script. Defaults to "test-script.yaml".
+	// Cannot be set along with an inline test script.
+	// +optional
+	Key string `json:"key,omitempty"`
 }
 
 type TestScript struct {
-	// +kubebuilder:validation:Required
-	Config   Config    `json:"config"`
+	// +optional
+	Config Config `json:"config,omitempty"`
+
+	// Inline test script, used instead of a test script ConfigMap for small tests.
+	// The operator stores the test script in a generated ConfigMap owned by the LoadTest,
+	// and deleted along with it.
+	// +optional
+	Inline string `json:"inline,omitempty"`
+
 	External *External `json:"external,omitempty"`
 }
 
+// Worker configures the pods created to run a load test.
+// These settings are merged over the pod spec generated by the operator.
+type Worker struct {
+	// Resources required by every worker's Artillery container.
+	// +optional
+	Resources core.ResourceRequirements `json:"resources,omitempty"`
+
+	// NodeSelector must match a node's labels for a worker to be scheduled on that node.
+	// +optional
+	NodeSelector map[string]string `json:"nodeSelector,omitempty"`
+
+	// Tolerations allow workers to be scheduled on nodes with matching taints.
+	// +optional
+	Tolerations []core.Toleration `json:"tolerations,omitempty"`
+
+	// Affinity defines worker scheduling constraints.
+	// +optional
+	Affinity *core.Affinity `json:"affinity,omitempty"`
+
+	// TopologySpreadConstraints describes how workers are spread across topology domains.
+	// Workers can be selected using the artillery.io/test-name label.
+	// +optional
+	TopologySpreadConstraints []core.TopologySpreadConstraint `json:"topologySpreadConstraints,omitempty"`
+
+	// ServiceAccountName is the name of the ServiceAccount used to run workers.
+	// +optional
+	ServiceAccountName string `json:"serviceAccountName,omitempty"`
+
+	// Annotations added to every worker pod.
+	// +optional
+	Annotations map[string]string `json:"annotations,omitempty"`
+
+	// Labels added to every worker pod.
+	// Labels used by the operator to select workers (artillery.io/*) cannot be overridden.
+	// +optional
+	Labels map[string]string `json:"labels,omitempty"`
+
+	// SecurityContext holds pod-level security attributes for workers.
+	// +optional
+	SecurityContext *core.PodSecurityContext `json:"securityContext,omitempty"`
+
+	// ContainerSecurityContext holds security attributes for every worker's Artillery container.
+	// +optional
+	ContainerSecurityContext *core.SecurityContext `json:"containerSecurityContext,omitempty"`
+}
+
+// Metrics configures how workers publish test report metrics.
+type Metrics struct {
+	// Prometheus publishes test report metrics to a Prometheus Pushgateway.
+	// +optional
+	Prometheus *PrometheusMetrics `json:"prometheus,omitempty"`
+}
+
+// PrometheusMetrics configures the Artillery publish-metrics plugin to push test report metrics
+// to a Prometheus Pushgateway.
+type PrometheusMetrics struct {
+	// Pushgateway URL, e.g. "http://prometheus-pushgateway:9091".
+	// +kubebuilder:validation:MinLength=1
+	Pushgateway string `json:"pushgateway"`
+
+	// Prefix of published metric names. Defaults to "artillery_k8s".
+	// +optional
+	Prefix string `json:"prefix,omitempty"`
+
+	// Tags added to published metrics in "name:value" format, e.g. "team:checkout".
+	// Metrics are always tagged with the LoadTest's load_test_id, namespace and worker_id.
+	// +optional
+	Tags []string `json:"tags,omitempty"`
+
+	// RetentionSeconds is how long published metrics are kept on the Pushgateway once the LoadTest finishes.
+	// Defaults to keeping them until the LoadTest is deleted, metrics are always deleted along with the LoadTest.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	RetentionSeconds *int64 `json:"retentionSeconds,omitempty"`
+}
+
+// Archive configures where worker logs and the final status of a LoadTest are archived when it is deleted.
+// Exactly one of ConfigMap or ObjectStorage must be set.
+type Archive struct {
+	// ConfigMap archives to a ConfigMap in the LoadTest's namespace.
+	// Logs are truncated to their most recent lines to fit in the ConfigMap.
+	// +optional
+	ConfigMap *ArchiveConfigMap `json:"configMap,omitempty"`
+
+	// ObjectStorage uploads archived files to an HTTP object storage endpoint.
+	// +optional
+	ObjectStorage *ArchiveObjectStorage `json:"objectStorage,omitempty"`
+}
+
+// ArchiveConfigMap configures a ConfigMap archive.
+type ArchiveConfigMap struct {
+	// Name of the archive ConfigMap. Defaults to "<loadtest-name>-archive".
+	// +optional
+	Name string `json:"name,omitempty"`
+}
+
+// ArchiveObjectStorage configures an object storage archive.
+type ArchiveObjectStorage struct {
+	// URL every archived file is uploaded under with a PUT request,
+	// as "<url>/<namespace>/<loadtest-name>/<file>", e.g. "http://minio:9000/loadtests".
+	// +kubebuilder:validation:MinLength=1
+	URL string `json:"url"`
+}
+
+// Distribution describes how a test script's load phases are run across workers.
+// +kubebuilder:validation:Enum=Replicate;Split
+type Distribution string
+
+const (
+	// DistributionReplicate runs the test script's load phases as-is on every worker,
+	// so the load generated by the LoadTest is multiplied by its worker count.
+	DistributionReplicate Distribution = "Replicate"
+	// DistributionSplit divides the arrivalRate, rampTo and arrivalCount of every load phase across workers,
+	// so the load generated by the LoadTest matches the test script.
+	DistributionSplit Distribution = "Split"
+)
+
 // LoadTestSpec defines the desired state of LoadTest
 type LoadTestSpec struct {
 	// INSERT ADDITIONAL SPEC FIELDS - desired state of cluster
 	// Important: Run "make" to regenerate code after modifying this file
 
-	Count       int    `json:"count,omitempty"`
+	Count int `json:"count,omitempty"`
+
+	// Distribution specifies how the test script's load phases are run across workers, one of:
+	// - "Replicate" (default): every worker runs the load phases as-is;
+	// - "Split": the arrivalRate, rampTo and arrivalCount of every load phase are divided across workers.
+	// +optional
+	Distribution Distribution `json:"distribution,omitempty"`
+
+	// Metrics configures how workers publish test report metrics.
+	// The operator adds the matching plugin configuration to the test script run by workers.
+	// +optional
+	Metrics *Metrics `json:"metrics,omitempty"`
+
+	// Archive preserves worker logs and the final status when the LoadTest is deleted,
+	// before its Job and worker Pods are garbage collected.
+	// +optional
+	Archive *Archive `json:"archive,omitempty"`
+
+	// Environment selects one of the environments defined in the test script's config.environments.
+	// It is passed to every worker using Artillery's --environment flag.
 	Environment string `json:"environment,omitempty"`
 
 	// +kubebuilder:validation:Required
 	TestScript TestScript `json:"testScript"`
+
+	// Image is the Artillery image used by workers to run the load test.
+	// Defaults to the operator's configured worker image.
+	// +optional
+	Image string `json:"image,omitempty"`
+
+	// ImagePullPolicy for the worker image, one of Always, Never or IfNotPresent.
+	// Defaults to Always if the :latest tag is specified, or IfNotPresent otherwise.
+	// +kubebuilder:validation:Enum=Always;Never;IfNotPresent
+	// +optional
+	ImagePullPolicy core.PullPolicy `json:"imagePullPolicy,omitempty"`
+
+	// ImagePullSecrets references Secrets in the LoadTest's namespace used to pull the worker image.
+	// +optional
+	ImagePullSecrets []core.LocalObjectReference `json:"imagePullSecrets,omitempty"`
+
+	// Worker configures the resources, scheduling and security settings of worker pods.
+	// +optional
+	Worker *Worker `json:"worker,omitempty"`
+
+	// Env lists environment variables set in every worker, e.g. for use in a test script
+	// with {{ $processEnvironment.API_TOKEN }}. Values can be literals or taken from Secrets and ConfigMaps.
+	// +optional
+	Env []core.EnvVar `json:"env,omitempty"`
+
+	// EnvFrom lists Secrets and ConfigMaps used to populate environment variables in every worker.
+	// +optional
+	EnvFrom []core.EnvFromSource `json:"envFrom,omitempty"`
+
+	// Thresholds lists SLO assertions evaluated against the aggregated test report once the load test has finished,
+	// e.g. "p95 < 300ms" or "errorRate < 1%". Results are recorded in the SLOMet condition.
+	// +optional
+	Thresholds []Threshold `json:"thresholds,omitempty"`
+
+	// ActiveDeadlineSeconds limits how long, in seconds, workers may run for before they're stopped
+	// and the LoadTest fails with reason DeadlineExceeded.
+	// +kubebuilder:validation:Minimum=1
+	// +optional
+	ActiveDeadlineSeconds *int64 `json:"activeDeadlineSeconds,omitempty"`
+
+	// TTLSecondsAfterFinished is how long, in seconds, the LoadTest and its worker Job are kept once it finishes,
+	// before they're deleted. Defaults to the operator's TTL, LoadTests are otherwise kept until deleted.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	TTLSecondsAfterFinished *int32 `json:"ttlSecondsAfterFinished,omitempty"`
+
+	// Suspend pauses the load test by stopping its running workers, and resumes it when unset.
+	// Resumed workers restart the test script from the beginning.
+	// +optional
+	Suspend *bool `json:"suspend,omitempty"`
+
+	// Abort stops the load test by deleting its workers, while keeping the LoadTest and its status.
+	// An aborted LoadTest cannot be resumed.
+	// +optional
+	Abort bool `json:"abort,omitempty"`
 }
 
+// Threshold is an SLO assertion in the form "<metric> <operator> <value>", e.g. "p95 < 300ms".
+// Supported metrics are min, max, p50, p95, p99, errors, errorRate, requests, responses, rps and vusersFailed.
+// Supported operators are <, <=, >, >= and ==.
+// Latency values accept ms or s units, defaulting to ms, and errorRate values accept a % unit.
+// +kubebuilder:validation:Pattern=`^\s*[A-Za-z0-9]+\s*(<=|>=|==|<|>)\s*[0-9]+(\.[0-9]+)?\s*(ms|s|%)?\s*$`
+type Threshold string
+
 // LoadTestConditionType creates types for K8s Conditions created by the operator
 type LoadTestConditionType string
 
@@ -71,11 +284,40 @@ const (
 	LoadTestProgressing LoadTestConditionType = "Progressing"
 	// LoadTestCompleted means the load test has completed its execution.
 	LoadTestCompleted LoadTestConditionType = "Completed"
+	// LoadTestFailed means the load test could not be started, or one or more of its workers failed.
+	// The condition's reason explains what went wrong, e.g. WorkerFailed, MissingTestScript or ImagePullError.
+	LoadTestFailed LoadTestConditionType = "Failed"
+	// LoadTestSLOMet means the load test's aggregated test report meets all its thresholds.
+	// The condition is False with reason SLOViolated, listing the failing thresholds, if any threshold is violated.
+	LoadTestSLOMet LoadTestConditionType = "SLOMet"
+	// LoadTestAborted means the load test was stopped using spec.abort before its workers completed.
+	LoadTestAborted LoadTestConditionType = "Aborted"
+	// LoadTestScriptInvalid means the load test's test script could not be parsed, or is missing required sections.
+	// The condition's message lists every problem found along with its line number.
+	LoadTestScriptInvalid LoadTestConditionType = "ScriptInvalid"
+)
+
+// LoadTestPhase is a high-level summary of where a LoadTest is in its lifecycle.
+// +kubebuilder:validation:Enum=Pending;Running;Succeeded;Failed;Aborted
+type LoadTestPhase string
+
+// These are valid phases of a load-test.
+const (
+	// LoadTestPhasePending means the load test has been accepted, but its workers are not running yet.
+	LoadTestPhasePending LoadTestPhase = "Pending"
+	// LoadTestPhaseRunning means the load test's workers are running.
+	LoadTestPhaseRunning LoadTestPhase = "Running"
+	// LoadTestPhaseSucceeded means all the load test's workers completed successfully.
+	LoadTestPhaseSucceeded LoadTestPhase = "Succeeded"
+	// LoadTestPhaseFailed means the load test could not be started, or one or more of its workers failed.
+	LoadTestPhaseFailed LoadTestPhase = "Failed"
+	// LoadTestPhaseAborted means the load test was stopped before its workers completed.
+	LoadTestPhaseAborted LoadTestPhase = "Aborted"
 )
 
 // LoadTestCondition provides a standard mechanism for higher-level status reporting
 type LoadTestCondition struct {
-	// Type of job condition, Progressing, Complete or Failed.
+	// Type of load test condition, Progressing, Completed, Failed, SLOMet, Aborted or ScriptInvalid.
 	Type LoadTestConditionType `json:"type"`
 	// Status of the condition, one of True, False, Unknown.
 	Status core.ConditionStatus `json:"status"`
@@ -93,11 +335,131 @@ type LoadTestCondition struct {
 	Message string `json:"message,omitempty"`
 }
 
+// LoadTestLatency summarises the response times of requests sent by LoadTest workers.
+type LoadTestLatency struct {
+	Min metav1.Duration `json:"min,omitempty"`
+	Max metav1.Duration `json:"max,omitempty"`
+	P50 metav1.Duration `json:"p50,omitempty"`
+	P95 metav1.Duration `json:"p95,omitempty"`
+	P99 metav1.Duration `json:"p99,omitempty"`
+}
+
+// LoadTestVUsers summarises the virtual users created by LoadTest workers.
+type LoadTestVUsers struct {
+	Created   int64 `json:"created"`
+	Completed int64 `json:"completed"`
+	Failed    int64 `json:"failed"`
+}
+
+// LoadTestReport summarises the final test reports of all LoadTest workers.
+type LoadTestReport struct {
+	// The number of workers whose final test report was aggregated.
+	Workers int32 `json:"workers"`
+
+	// The total number of requests sent by all workers.
+	Requests int64 `json:"requests"`
+
+	// The total number of responses received by all workers.
+	Responses int64 `json:"responses"`
+
+	// The number of requests sent per second across all workers.
+	RequestRate int64 `json:"requestRate"`
+
+	// The total number of errors, e.g. ETIMEDOUT or ECONNREFUSED, encountered by all workers.
+	Errors int64 `json:"errors"`
+
+	// Errors counted by their code, e.g. ETIMEDOUT or ECONNREFUSED.
+	// +optional
+	ErrorsByCode map[string]int64 `json:"errorsByCode,omitempty"`
+
+	// Responses counted by their HTTP status code.
+	// +optional
+	Codes map[string]int64 `json:"codes,omitempty"`
+
+	// Response time percentiles are approximated across workers
+	// as the mean of every worker's percentiles weighted by its number of responses.
+	Latency LoadTestLatency `json:"latency,omitempty"`
+
+	// Virtual users created by all workers.
+	VUsers LoadTestVUsers `json:"vusers,omitempty"`
+}
+
+// LoadTestWorker describes the observed state of a single LoadTest worker pod.
+type LoadTestWorker struct {
+	// Index of the worker, from 0 to count - 1. A retried worker keeps its index.
+	// +optional
+	Index *int32 `json:"index,omitempty"`
+
+	// Name of the worker pod.
+	Name string `json:"name"`
+
+	// Node the worker pod is scheduled on.
+	// +optional
+	Node string `json:"node,omitempty"`
+
+	// Phase of the worker pod, one of Pending, Running, Succeeded, Failed or Unknown.
+	// +optional
+	Phase core.PodPhase `json:"phase,omitempty"`
+
+	// Time the worker pod was started by its node.
+	// +optional
+	StartTime *metav1.Time `json:"startTime,omitempty"`
+
+	// Time the worker's Artillery container finished.
+	// +optional
+	FinishTime *metav1.Time `json:"finishTime,omitempty"`
+
+	// Exit code of the worker's Artillery container, once finished.
+	// +optional
+	ExitCode *int32 `json:"exitCode,omitempty"`
+
+	// Reason the worker's Artillery container finished, e.g. Completed, Error or OOMKilled,
+	// or is waiting to start, e.g. ContainerCreating.
+	// +optional
+	Reason string `json:"reason,omitempty"`
+
+	// The number of times the worker's Artillery container restarted.
+	// +optional
+	RestartCount int32 `json:"restartCount,omitempty"`
+}
+
+// LoadTestScriptStatus identifies the test script revision run by a LoadTest's workers.
+type LoadTestScriptStatus struct {
+	// ConfigMap holding the test script.
+	ConfigMap string `json:"configMap"`
+
+	// ResourceVersion of the test script ConfigMap.
+	// +optional
+	ResourceVersion string `json:"resourceVersion,omitempty"`
+
+	// Hash of the test script, e.g. "sha256:2c26b46b68ffc68ff99b453c1d30413413422d706483bfa0f98a5e886266e7ae".
+	Hash string `json:"hash"`
+
+	// Snapshot identifies the immutable copy of the test script and external files run by workers.
+	// +optional
+	Snapshot *LoadTestSnapshotStatus `json:"snapshot,omitempty"`
+}
+
+// LoadTestSnapshotStatus identifies the immutable ConfigMap holding a copy of the test script
+// and external payload and processor files, taken when the LoadTest's workers are created.
+type LoadTestSnapshotStatus struct {
+	// ConfigMap holding the snapshot.
+	ConfigMap string `json:"configMap"`
+
+	// Hash of the snapshot's content, covering every file.
+	Hash string `json:"hash"`
+}
+
 // LoadTestStatus defines the observed state of LoadTest.
 type LoadTestStatus struct {
 	// Important: Run "make" to regenerate code after modifying this file.
 	Conditions []LoadTestCondition `json:"conditions,omitempty"`
 
+	// Phase is a high-level summary of where the LoadTest is in its lifecycle.
+	// One of Pending, Running, Succeeded, Failed or Aborted.
+	// +optional
+	Phase LoadTestPhase `json:"phase,omitempty"`
+
 	// Represents time when the loadtest controller started processing a loadtest.
 	// It is represented in RFC3339 form and is in UTC.
 	// +optional
@@ -106,7 +468,7 @@ type LoadTestStatus struct {
 	// Represents time when the loadtest was completed. It is not guaranteed to
 	// be set in happens-before order across separate operations.
 	// It is represented in RFC3339 form and is in UTC.
-	// The completion time is only set when the loadtest finishes successfully.
+	// The completion time is set when the loadtest finishes, fails or is aborted.
 	// +optional
 	CompletionTime *metav1.Time `json:"completionTime,omitempty"`
 
@@ -123,21 +485,50 @@ type LoadTestStatus struct {
 	// The number of LoadTest worker pods which reached phase Failed.
 	Failed int32 `json:"failed,omitempty"`
 
+	// Workers lists every LoadTest worker pod, ordered by worker index.
+	// Workers are kept once the load test has finished, even if their pods are deleted.
+	// +optional
+	Workers []LoadTestWorker `json:"workers,omitempty"`
+
 	// Formatted load test worker pod completions calculated from the underlying succeeded jobs vs configured
 	// job completions/parallelism.
 	Completions string `json:"completions,omitempty"`
 
 	// The image used to run the load tests.
 	Image string `json:"image,omitempty"`
+
+	// Report aggregates the final test reports of all workers, once the load test has finished.
+	// +optional
+	Report *LoadTestReport `json:"report,omitempty"`
+
+	// MetricsDeletionTime is the time the LoadTest's published metrics were deleted from the Pushgateway.
+	// +optional
+	MetricsDeletionTime *metav1.Time `json:"metricsDeletionTime,omitempty"`
+
+	// TestScript identifies the test script revision run by workers.
+	// It tracks test script updates until the test script snapshot is taken.
+	// +optional
+	TestScript *LoadTestScriptStatus `json:"testScript,omitempty"`
 }
 
 // +kubebuilder:object:root=true
 // +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Phase",type="string",JSONPath=`.status.phase`
+// +kubebuilder:printcolumn:name="Reason",type="string",JSONPath=`.status.conditions[?(@.type=="Failed")].reason`
 // +kubebuilder:printcolumn:name="Completions",type="string",JSONPath=`.status.completions`
 // +kubebuilder:printcolumn:name="Duration",type="string",JSONPath=`.status.duration`
 // +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
 // +kubebuilder:printcolumn:name="Environment",type=string,JSONPath=`.spec.environment`
 // +kubebuilder:printcolumn:name="Image",type=string,JSONPath=`.status.image`,priority=10
+// +kubebuilder:printcolumn:name="Requests",type=integer,JSONPath=`.status.report.requests`,priority=10
+// +kubebuilder:printcolumn:name="RPS",type=integer,JSONPath=`.status.report.requestRate`,priority=10
+// +kubebuilder:printcolumn:name="Errors",type=integer,JSONPath=`.status.report.errors`,priority=10
+// +kubebuilder:printcolumn:name="VUsers Created",type=integer,JSONPath=`.status.report.vusers.created`,priority=10
+// +kubebuilder:printcolumn:name="VUsers Completed",type=integer,JSONPath=`.status.report.vusers.completed`,priority=10
+// +kubebuilder:printcolumn:name="VUsers Failed",type=integer,JSONPath=`.status.report.vusers.failed`,priority=10
+// +kubebuilder:printcolumn:name="P95",type=string,JSONPath=`.status.report.latency.p95`,priority=10
+// +kubebuilder:printcolumn:name="P99",type=string,JSONPath=`.status.report.latency.p99`,priority=10
+// +kubebuilder:printcolumn:name="SLO Met",type=string,JSONPath=`.status.conditions[?(@.type=="SLOMet")].status`,priority=10
 
 // LoadTest is the Schema for the loadTests API.
 type LoadTest struct {
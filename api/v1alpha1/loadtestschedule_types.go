@@ -0,0 +1,132 @@
+/*
+ * Copyright (c) 2022.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0.
+ *
+ * If a copy of the MPL was not distributed with
+ * this file, You can obtain one at
+ *
+ *   http://mozilla.org/MPL/2.0/
+ */
+
+package v1alpha1
+
+import (
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// ConcurrencyPolicy describes how a scheduled LoadTest run is handled
+// when the previous run is still in progress.
+// +kubebuilder:validation:Enum=Allow;Forbid;Replace
+type ConcurrencyPolicy string
+
+const (
+	// AllowConcurrent allows LoadTest runs to run concurrently.
+	AllowConcurrent ConcurrencyPolicy = "Allow"
+	// ForbidConcurrent skips the next run if the previous run hasn't finished yet.
+	ForbidConcurrent ConcurrencyPolicy = "Forbid"
+	// ReplaceConcurrent aborts the currently running LoadTest run and replaces it with a new one.
+	ReplaceConcurrent ConcurrencyPolicy = "Replace"
+)
+
+// LoadTestTemplateSpec describes the LoadTest created for every scheduled run.
+type LoadTestTemplateSpec struct {
+	// Labels and annotations copied to every LoadTest run.
+	// +optional
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	// +kubebuilder:validation:Required
+	Spec LoadTestSpec `json:"spec"`
+}
+
+// LoadTestScheduleSpec defines the desired state of LoadTestSchedule
+type LoadTestScheduleSpec struct {
+	// Schedule in Cron format, e.g. "0 2 * * *" for a nightly run at 2am.
+	// See https://en.wikipedia.org/wiki/Cron.
+	// +kubebuilder:validation:MinLength=1
+	Schedule string `json:"schedule"`
+
+	// TimeZone is the IANA time zone name used to interpret the schedule, e.g. "Europe/London".
+	// Defaults to the operator's local time zone, usually UTC.
+	// +optional
+	TimeZone *string `json:"timeZone,omitempty"`
+
+	// StartingDeadlineSeconds is the deadline, in seconds, for starting a run that missed its scheduled time.
+	// Runs missed by more than the deadline are not started.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	StartingDeadlineSeconds *int64 `json:"startingDeadlineSeconds,omitempty"`
+
+	// ConcurrencyPolicy specifies how to treat concurrent runs, one of:
+	// - "Allow" (default): allows LoadTest runs to run concurrently;
+	// - "Forbid": skips the next run if the previous run hasn't finished yet;
+	// - "Replace": aborts the currently running LoadTest run and replaces it with a new one.
+	// +optional
+	ConcurrencyPolicy ConcurrencyPolicy `json:"concurrencyPolicy,omitempty"`
+
+	// Suspend stops subsequent runs from being scheduled. Runs already in progress are not affected.
+	// +optional
+	Suspend *bool `json:"suspend,omitempty"`
+
+	// LoadTestTemplate describes the LoadTest created for every scheduled run.
+	LoadTestTemplate LoadTestTemplateSpec `json:"loadTestTemplate"`
+
+	// The number of succeeded LoadTest runs to keep. Defaults to 3.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	SuccessfulRunsHistoryLimit *int32 `json:"successfulRunsHistoryLimit,omitempty"`
+
+	// The number of failed or aborted LoadTest runs to keep. Defaults to 1.
+	// +kubebuilder:validation:Minimum=0
+	// +optional
+	FailedRunsHistoryLimit *int32 `json:"failedRunsHistoryLimit,omitempty"`
+}
+
+// LoadTestScheduleStatus defines the observed state of LoadTestSchedule
+type LoadTestScheduleStatus struct {
+	// Active lists the LoadTest runs currently in progress.
+	// +optional
+	Active []core.ObjectReference `json:"active,omitempty"`
+
+	// LastScheduleTime is the last time a LoadTest run was scheduled,
+	// including runs skipped by the Forbid concurrency policy.
+	// +optional
+	LastScheduleTime *metav1.Time `json:"lastScheduleTime,omitempty"`
+
+	// LastSuccessfulTime is the last time a LoadTest run succeeded.
+	// +optional
+	LastSuccessfulTime *metav1.Time `json:"lastSuccessfulTime,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+// +kubebuilder:subresource:status
+// +kubebuilder:printcolumn:name="Schedule",type="string",JSONPath=`.spec.schedule`
+// +kubebuilder:printcolumn:name="Time Zone",type="string",JSONPath=`.spec.timeZone`
+// +kubebuilder:printcolumn:name="Suspend",type="boolean",JSONPath=`.spec.suspend`
+// +kubebuilder:printcolumn:name="Last Schedule",type="date",JSONPath=`.status.lastScheduleTime`
+// +kubebuilder:printcolumn:name="Age",type="date",JSONPath=".metadata.creationTimestamp"
+
+// LoadTestSchedule is the Schema for the loadTestSchedules API.
+// It creates LoadTests from a template on a recurring schedule.
+type LoadTestSchedule struct {
+	metav1.TypeMeta   `json:",inline"`
+	metav1.ObjectMeta `json:"metadata,omitempty"`
+
+	Spec   LoadTestScheduleSpec   `json:"spec,omitempty"`
+	Status LoadTestScheduleStatus `json:"status,omitempty"`
+}
+
+// +kubebuilder:object:root=true
+
+// LoadTestScheduleList contains a list of LoadTestSchedule.
+type LoadTestScheduleList struct {
+	metav1.TypeMeta `json:",inline"`
+	metav1.ListMeta `json:"metadata,omitempty"`
+	Items           []LoadTestSchedule `json:"items"`
+}
+
+func init() {
+	SchemeBuilder.Register(&LoadTestSchedule{}, &LoadTestScheduleList{})
+}
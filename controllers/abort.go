@@ -0,0 +1,131 @@
+/*
+ * Copyright (c) 2022.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0.
+ *
+ * If a copy of the MPL was not distributed with
+ * this file, You can obtain one at
+ *
+ *   http://mozilla.org/MPL/2.0/
+ */
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	lt "github.com/artilleryio/artillery-operator/api/v1alpha1"
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ensureAbort stops an aborted LoadTest by deleting its worker Job.
+// The LoadTest is kept with an Aborted condition, an Aborted phase and a completion time,
+// and a Warning event records who aborted it.
+// Once aborted, the worker Job is never recreated.
+func (r *LoadTestReconciler) ensureAbort(ctx context.Context,
+	instance *lt.LoadTest,
+	logger logr.Logger) (*ctrl.Result, error) {
+	aborted, ok := conditionsMap(instance.Status.Conditions)[lt.LoadTestAborted]
+	if ok && aborted.Status == corev1.ConditionTrue {
+		// Already aborted, only requeue to delete published metrics or the LoadTest once they expire
+		return &ctrl.Result{RequeueAfter: r.finishedRequeueAfter(instance, time.Now())}, nil
+	}
+	if !instance.Spec.Abort {
+		return nil, nil
+	}
+	if instance.Status.CompletionTime != nil {
+		logger.Info("LoadTest has already finished, ignoring abort")
+		return nil, nil
+	}
+
+	found := &v1.Job{}
+	err := r.Get(ctx, types.NamespacedName{
+		Name:      instance.Name,
+		Namespace: instance.Namespace,
+	}, found)
+	if err != nil && !errors.IsNotFound(err) {
+		logger.Error(err, "Failed to get Job", "Job.Namespace", instance.Namespace, "Job.Name", instance.Name)
+		return &ctrl.Result{}, err
+	}
+
+	if err == nil {
+		logger.Info("Deleting aborted Job", "Job.Namespace", found.Namespace, "Job.Name", found.Name)
+		if err := r.Delete(ctx, found, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+			logger.Error(err, "Failed to delete Job", "Job.Namespace", found.Namespace, "Job.Name", found.Name)
+			return &ctrl.Result{}, err
+		}
+	}
+
+	msg := fmt.Sprintf("Load Test aborted by %s", fieldManager(instance, "f:spec", "f:abort"))
+	r.Recorder.Event(instance, "Warning", ReasonAborted, msg)
+
+	setCondition(instance, lt.LoadTestProgressing, corev1.ConditionFalse, ReasonAborted, msg)
+	setCondition(instance, lt.LoadTestAborted, corev1.ConditionTrue, ReasonAborted, msg)
+	instance.Status.Phase = lt.LoadTestPhaseAborted
+	instance.Status.Active = 0
+	if instance.Status.CompletionTime == nil {
+		now := metav1.Now()
+		instance.Status.CompletionTime = &now
+	}
+	instance.Status.Duration = loadTestDuration(instance.Status)
+
+	if err := r.Status().Update(ctx, instance); err != nil {
+		logger.Error(err, "Failed to update LoadTest status")
+		return &ctrl.Result{}, err
+	}
+
+	return &ctrl.Result{}, nil
+}
+
+// fieldManager returns the name of the manager, e.g. kubectl-patch, that last set the field at the provided path
+// according to the object's managed fields. Returns "unknown" if no manager is found.
+func fieldManager(obj metav1.Object, path ...string) string {
+	var (
+		manager = "unknown"
+		latest  *metav1.Time
+	)
+
+	for _, entry := range obj.GetManagedFields() {
+		if entry.FieldsV1 == nil || entry.Manager == "" {
+			continue
+		}
+
+		var fields map[string]interface{}
+		if err := json.Unmarshal(entry.FieldsV1.Raw, &fields); err != nil || !hasFieldPath(fields, path) {
+			continue
+		}
+
+		if latest == nil || (entry.Time != nil && entry.Time.After(latest.Time)) {
+			manager = entry.Manager
+			latest = entry.Time
+			if latest == nil {
+				latest = &metav1.Time{}
+			}
+		}
+	}
+
+	return manager
+}
+
+// hasFieldPath returns whether managed fields hold the provided path.
+func hasFieldPath(fields map[string]interface{}, path []string) bool {
+	for _, p := range path {
+		next, ok := fields[p].(map[string]interface{})
+		if !ok {
+			return false
+		}
+		fields = next
+	}
+	return true
+}
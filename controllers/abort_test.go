@@ -0,0 +1,173 @@
+/*
+ * Copyright (c) 2022.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0.
+ *
+ * If a copy of the MPL was not distributed with
+ * this file, You can obtain one at
+ *
+ *   http://mozilla.org/MPL/2.0/
+ */
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	lt "github.com/artilleryio/artillery-operator/api/v1alpha1"
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func managedFields(manager string, at time.Time, fields string) metav1.ManagedFieldsEntry {
+	t := metav1.NewTime(at)
+	return metav1.ManagedFieldsEntry{
+		Manager:  manager,
+		Time:     &t,
+		FieldsV1: &metav1.FieldsV1{Raw: []byte(fields)},
+	}
+}
+
+func TestFieldManager(t *testing.T) {
+	now := time.Now()
+
+	tests := []struct {
+		name   string
+		fields []metav1.ManagedFieldsEntry
+		want   string
+	}{
+		{
+			name: "no managed fields",
+			want: "unknown",
+		},
+		{
+			name: "field set on create",
+			fields: []metav1.ManagedFieldsEntry{
+				managedFields("kubectl-client-side-apply", now, `{"f:spec":{"f:abort":{},"f:count":{}}}`),
+			},
+			want: "kubectl-client-side-apply",
+		},
+		{
+			name: "field set by a later patch",
+			fields: []metav1.ManagedFieldsEntry{
+				managedFields("kubectl-client-side-apply", now.Add(-time.Minute), `{"f:spec":{"f:count":{}}}`),
+				managedFields("kubectl-patch", now, `{"f:spec":{"f:abort":{}}}`),
+				managedFields("artillery-operator", now.Add(time.Minute), `{"f:status":{"f:phase":{}}}`),
+			},
+			want: "kubectl-patch",
+		},
+		{
+			name: "latest manager wins",
+			fields: []metav1.ManagedFieldsEntry{
+				managedFields("ci-pipeline", now.Add(time.Minute), `{"f:spec":{"f:abort":{}}}`),
+				managedFields("kubectl-patch", now, `{"f:spec":{"f:abort":{}}}`),
+			},
+			want: "ci-pipeline",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &lt.LoadTest{ObjectMeta: metav1.ObjectMeta{ManagedFields: tt.fields}}
+			if got := fieldManager(v, "f:spec", "f:abort"); got != tt.want {
+				t.Errorf("fieldManager() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnsureAbort(t *testing.T) {
+	ctx := context.Background()
+	started := metav1.NewTime(time.Now().Add(-time.Minute))
+	v := &lt.LoadTest{
+		ObjectMeta: metav1.ObjectMeta{Name: "basic-test", Namespace: "default", UID: "uid"},
+		Spec:       lt.LoadTestSpec{Count: 1, Abort: true},
+		Status:     lt.LoadTestStatus{Phase: lt.LoadTestPhaseRunning, StartTime: &started, Active: 1},
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	r := &LoadTestReconciler{Scheme: testScheme(), Recorder: recorder}
+	job := r.job(v)
+	r.Client = fake.NewClientBuilder().WithScheme(r.Scheme).WithObjects(v, job).Build()
+
+	if result, err := r.ensureAbort(ctx, v, logr.Discard()); result == nil || err != nil {
+		t.Fatalf("ensureAbort() = %v, %v, want a result and no error", result, err)
+	}
+
+	if err := r.Get(ctx, types.NamespacedName{Name: job.Name, Namespace: job.Namespace}, &v1.Job{}); !errors.IsNotFound(err) {
+		t.Errorf("ensureAbort() did not delete the Job")
+	}
+
+	found := &lt.LoadTest{}
+	if err := r.Get(ctx, types.NamespacedName{Name: v.Name, Namespace: v.Namespace}, found); err != nil {
+		t.Fatal(err)
+	}
+	aborted := conditionsMap(found.Status.Conditions)[lt.LoadTestAborted]
+	if aborted.Status != corev1.ConditionTrue || found.Status.Phase != lt.LoadTestPhaseAborted || found.Status.CompletionTime == nil {
+		t.Fatalf("ensureAbort() status = %+v, want an Aborted condition, phase and completion time", found.Status)
+	}
+	if len(recorder.Events) != 1 {
+		t.Errorf("ensureAbort() published %d events, want 1", len(recorder.Events))
+	}
+
+	// Reconciling an aborted LoadTest again does nothing
+	completion := found.Status.CompletionTime
+	if result, err := r.ensureAbort(ctx, found, logr.Discard()); result == nil || err != nil {
+		t.Fatalf("ensureAbort() = %v, %v, want a result and no error", result, err)
+	}
+	if len(recorder.Events) != 1 {
+		t.Errorf("ensureAbort() published %d events after reconciling again, want 1", len(recorder.Events))
+	}
+	if !found.Status.CompletionTime.Equal(completion) {
+		t.Errorf("ensureAbort() completion time = %v, want %v", found.Status.CompletionTime, completion)
+	}
+}
+
+func TestEnsureJobSuspend(t *testing.T) {
+	ctx := context.Background()
+	v := &lt.LoadTest{
+		ObjectMeta: metav1.ObjectMeta{Name: "basic-test", Namespace: "default", UID: "uid"},
+		Spec:       lt.LoadTestSpec{Count: 1},
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	r := &LoadTestReconciler{Scheme: testScheme(), Recorder: recorder}
+	job := r.job(v)
+	r.Client = fake.NewClientBuilder().WithScheme(r.Scheme).WithObjects(v, job).Build()
+
+	tests := []struct {
+		suspend   bool
+		wantEvent string
+	}{
+		{suspend: true, wantEvent: "Normal Suspended"},
+		{suspend: false, wantEvent: "Normal Resumed"},
+	}
+
+	for _, tt := range tests {
+		v.Spec.Suspend = &tt.suspend
+		if result, err := r.ensureJob(ctx, v, logr.Discard(), r.job(v)); result != nil || err != nil {
+			t.Fatalf("ensureJob() = %v, %v, want nil, nil", result, err)
+		}
+
+		found := &v1.Job{}
+		if err := r.Get(ctx, types.NamespacedName{Name: job.Name, Namespace: job.Namespace}, found); err != nil {
+			t.Fatal(err)
+		}
+		if got := boolValue(found.Spec.Suspend); got != tt.suspend {
+			t.Errorf("ensureJob() Job suspend = %v, want %v", got, tt.suspend)
+		}
+		if event := <-recorder.Events; !strings.HasPrefix(event, tt.wantEvent) {
+			t.Errorf("ensureJob() event = %q, want %q", event, tt.wantEvent)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+/*
+ * Copyright (c) 2022.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0.
+ *
+ * If a copy of the MPL was not distributed with
+ * this file, You can obtain one at
+ *
+ *   http://mozilla.org/MPL/2.0/
+ */
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+
+	lt "github.com/artilleryio/artillery-operator/api/v1alpha1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+const (
+	// ArchiveFinalizer ensures worker logs and the final status are archived before a LoadTest is deleted.
+	ArchiveFinalizer = "loadtest.artillery.io/archive"
+
+	// archiveStatusFile holds the LoadTest's final status in an archive.
+	archiveStatusFile = "status.json"
+
+	// archiveConfigMapMaxBytes keeps archive ConfigMaps below the 1MiB ConfigMap size limit.
+	archiveConfigMapMaxBytes = 900 * 1024
+)
+
+// archiveSink stores the files archived for a deleted LoadTest.
+type archiveSink interface {
+	store(ctx context.Context, v *lt.LoadTest, files map[string][]byte) error
+	// location describes where files are stored, e.g. for events.
+	location(v *lt.LoadTest) string
+}
+
+// archives checks if a LoadTest archives worker logs and its final status when deleted.
+func archives(v *lt.LoadTest) bool {
+	return v.Spec.Archive != nil && (v.Spec.Archive.ConfigMap != nil || v.Spec.Archive.ObjectStorage != nil)
+}
+
+// archiveSink returns the sink configured by a LoadTest's spec.archive.
+func (r *LoadTestReconciler) archiveSink(v *lt.LoadTest) archiveSink {
+	if v.Spec.Archive.ObjectStorage != nil {
+		httpClient := r.HTTPClient
+		if httpClient == nil {
+			httpClient = defaultHTTPClient
+		}
+		return &objectStorageArchive{httpClient: httpClient, url: v.Spec.Archive.ObjectStorage.URL}
+	}
+	return &configMapArchive{Client: r.Client}
+}
+
+// archive stores the worker logs and the final status of a LoadTest being deleted to its archive sink.
+// Workers whose logs can't be read, e.g. because they never started, are skipped.
+func (r *LoadTestReconciler) archive(ctx context.Context, v *lt.LoadTest, logger logr.Logger) error {
+	status, err := json.MarshalIndent(v.Status, "", "  ")
+	if err != nil {
+		return err
+	}
+	files := map[string][]byte{archiveStatusFile: status}
+
+	if r.KubeClient != nil {
+		podList, err := getPods(ctx, v, r.Client)
+		if err != nil {
+			return err
+		}
+
+		for _, pod := range podList.Items {
+			logs, err := r.workerLogs(ctx, v, pod)
+			if err != nil {
+				logger.Info("Could not read Load Test worker logs", "Pod.Name", pod.Name, "Error", err.Error())
+				continue
+			}
+			files[pod.Name+".log"] = logs
+		}
+	}
+
+	sink := r.archiveSink(v)
+	if err := sink.store(ctx, v, files); err != nil {
+		return err
+	}
+
+	logger.Info("Archived LoadTest", "Location", sink.location(v), "Files", len(files))
+	r.Recorder.Eventf(v, "Normal", "Archived", "Archived Load Test worker logs and status to %s", sink.location(v))
+	return nil
+}
+
+// workerLogs reads all logs of a worker Pod's container.
+func (r *LoadTestReconciler) workerLogs(ctx context.Context, v *lt.LoadTest, pod corev1.Pod) ([]byte, error) {
+	logs, err := r.KubeClient.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+		Container: v.Name,
+	}).Stream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = logs.Close()
+	}()
+
+	return io.ReadAll(logs)
+}
+
+// configMapArchive stores archived files as a ConfigMap in the LoadTest's namespace.
+// The ConfigMap is not owned by the LoadTest, so that it outlives it.
+type configMapArchive struct {
+	client.Client
+}
+
+// configMapArchiveName returns the name of a LoadTest's archive ConfigMap.
+func configMapArchiveName(v *lt.LoadTest) string {
+	if name := v.Spec.Archive.ConfigMap.Name; name != "" {
+		return name
+	}
+	return v.Name + "-archive"
+}
+
+func (a *configMapArchive) location(v *lt.LoadTest) string {
+	return "ConfigMap " + v.Namespace + "/" + configMapArchiveName(v)
+}
+
+func (a *configMapArchive) store(ctx context.Context, v *lt.LoadTest, files map[string][]byte) error {
+	data := truncateArchive(files, archiveConfigMapMaxBytes)
+
+	found := &corev1.ConfigMap{}
+	err := a.Get(ctx, types.NamespacedName{Name: configMapArchiveName(v), Namespace: v.Namespace}, found)
+	if err != nil && !errors.IsNotFound(err) {
+		return err
+	}
+
+	if errors.IsNotFound(err) {
+		return a.Create(ctx, &corev1.ConfigMap{
+			ObjectMeta: metav1.ObjectMeta{
+				Name:      configMapArchiveName(v),
+				Namespace: v.Namespace,
+				Labels:    labels(v, "loadtest-archive"),
+			},
+			Data: data,
+		})
+	}
+
+	// Overwrite an archive left by a previous attempt, but never another ConfigMap
+	if found.Labels["artillery.io/test-name"] != v.Name || found.Labels["artillery.io/component"] != "loadtest-archive" {
+		return fmt.Errorf("ConfigMap %s already exists and is not an archive of LoadTest %s", found.Name, v.Name)
+	}
+	found.Data = data
+	return a.Update(ctx, found)
+}
+
+// truncateArchive converts archived files to ConfigMap data of at most maxBytes.
+// The final status is kept as-is and logs share the remaining space, keeping their most recent lines.
+func truncateArchive(files map[string][]byte, maxBytes int) map[string]string {
+	data := map[string]string{archiveStatusFile: string(files[archiveStatusFile])}
+
+	var logs []string
+	for name := range files {
+		if name != archiveStatusFile {
+			logs = append(logs, name)
+		}
+	}
+	if len(logs) == 0 {
+		return data
+	}
+	sort.Strings(logs)
+
+	limit := (maxBytes - len(data[archiveStatusFile])) / len(logs)
+	if limit < 0 {
+		limit = 0
+	}
+	for _, name := range logs {
+		data[name] = string(tailBytes(files[name], limit))
+	}
+	return data
+}
+
+// tailBytes returns the last lines of b fitting in n bytes.
+func tailBytes(b []byte, n int) []byte {
+	if len(b) <= n {
+		return b
+	}
+	b = b[len(b)-n:]
+	if i := bytes.IndexByte(b, '\n'); i >= 0 {
+		return b[i+1:]
+	}
+	return nil
+}
+
+// objectStorageArchive uploads archived files to an HTTP object storage endpoint,
+// e.g. an S3 compatible bucket accepting unauthenticated PUT requests.
+type objectStorageArchive struct {
+	httpClient *http.Client
+	url        string
+}
+
+func (a *objectStorageArchive) location(v *lt.LoadTest) string {
+	return a.objectURL(v, "")
+}
+
+// objectURL returns the URL an archived file of a LoadTest is uploaded to.
+func (a *objectStorageArchive) objectURL(v *lt.LoadTest, file string) string {
+	return strings.TrimSuffix(a.url, "/") + "/" + url.PathEscape(v.Namespace) + "/" + url.PathEscape(v.Name) + "/" + url.PathEscape(file)
+}
+
+func (a *objectStorageArchive) store(ctx context.Context, v *lt.LoadTest, files map[string][]byte) error {
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		req, err := http.NewRequestWithContext(ctx, http.MethodPut, a.objectURL(v, name), bytes.NewReader(files[name]))
+		if err != nil {
+			return err
+		}
+		contentType := "text/plain; charset=utf-8"
+		if name == archiveStatusFile {
+			contentType = "application/json"
+		}
+		req.Header.Set("Content-Type", contentType)
+
+		resp, err := a.httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		_ = resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("failed to upload archived file %s: %s", name, resp.Status)
+		}
+	}
+	return nil
+}
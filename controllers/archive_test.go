@@ -0,0 +1,262 @@
+/*
+ * Copyright (c) 2022.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0.
+ *
+ * If a copy of the MPL was not distributed with
+ * this file, You can obtain one at
+ *
+ *   http://mozilla.org/MPL/2.0/
+ */
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+
+	lt "github.com/artilleryio/artillery-operator/api/v1alpha1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	k8sfake "k8s.io/client-go/kubernetes/fake"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+func archiveLoadTest(archive *lt.Archive) *lt.LoadTest {
+	return &lt.LoadTest{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "perf"},
+		Spec:       lt.LoadTestSpec{Count: 1, Archive: archive},
+		Status:     lt.LoadTestStatus{Succeeded: 1},
+	}
+}
+
+func archiveWorkerPod(v *lt.LoadTest) *corev1.Pod {
+	return &corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly-0-abcde", Namespace: v.Namespace, Labels: labels(v, "loadtest-worker")},
+	}
+}
+
+func TestArchiveConfigMap(t *testing.T) {
+	v := archiveLoadTest(&lt.Archive{ConfigMap: &lt.ArchiveConfigMap{}})
+	pod := archiveWorkerPod(v)
+
+	recorder := record.NewFakeRecorder(10)
+	r := &LoadTestReconciler{
+		Client:     fake.NewClientBuilder().WithScheme(testScheme()).WithObjects(v, pod).Build(),
+		Recorder:   recorder,
+		KubeClient: k8sfake.NewSimpleClientset(pod),
+	}
+
+	if err := r.archive(context.Background(), v, logr.Discard()); err != nil {
+		t.Fatalf("archive() error = %v", err)
+	}
+
+	archived := &corev1.ConfigMap{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "nightly-archive", Namespace: "perf"}, archived); err != nil {
+		t.Fatalf("archive() did not create the archive ConfigMap: %v", err)
+	}
+	if archived.Data["nightly-0-abcde.log"] != "fake logs" {
+		t.Errorf("archive() logs = %q, want %q", archived.Data["nightly-0-abcde.log"], "fake logs")
+	}
+	if !strings.Contains(archived.Data[archiveStatusFile], `"succeeded": 1`) {
+		t.Errorf("archive() status = %s", archived.Data[archiveStatusFile])
+	}
+	if len(archived.OwnerReferences) != 0 {
+		t.Errorf("archive() ConfigMap is owned, it would be deleted along with the LoadTest")
+	}
+	if event := <-recorder.Events; !strings.HasPrefix(event, "Normal Archived") {
+		t.Errorf("archive() event = %q", event)
+	}
+
+	// Archiving again overwrites the previous archive
+	if err := r.archive(context.Background(), v, logr.Discard()); err != nil {
+		t.Fatalf("archive() error = %v", err)
+	}
+}
+
+func TestArchiveConfigMapConflict(t *testing.T) {
+	v := archiveLoadTest(&lt.Archive{ConfigMap: &lt.ArchiveConfigMap{Name: "users-csv"}})
+	users := &corev1.ConfigMap{ObjectMeta: metav1.ObjectMeta{Name: "users-csv", Namespace: "perf"}}
+
+	r := &LoadTestReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(testScheme()).WithObjects(v, users).Build(),
+		Recorder: record.NewFakeRecorder(10),
+	}
+
+	if err := r.archive(context.Background(), v, logr.Discard()); err == nil {
+		t.Errorf("archive() overwrote a ConfigMap that is not an archive")
+	}
+}
+
+func TestArchiveObjectStorage(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		uploaded = map[string]string{}
+	)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		body, _ := io.ReadAll(req.Body)
+		if req.Method != http.MethodPut {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		mu.Lock()
+		uploaded[req.URL.Path] = string(body)
+		mu.Unlock()
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	v := archiveLoadTest(&lt.Archive{ObjectStorage: &lt.ArchiveObjectStorage{URL: server.URL + "/loadtests/"}})
+	pod := archiveWorkerPod(v)
+	r := &LoadTestReconciler{
+		Client:     fake.NewClientBuilder().WithScheme(testScheme()).WithObjects(v, pod).Build(),
+		Recorder:   record.NewFakeRecorder(10),
+		KubeClient: k8sfake.NewSimpleClientset(pod),
+		HTTPClient: server.Client(),
+	}
+
+	if err := r.archive(context.Background(), v, logr.Discard()); err != nil {
+		t.Fatalf("archive() error = %v", err)
+	}
+
+	if uploaded["/loadtests/perf/nightly/nightly-0-abcde.log"] != "fake logs" {
+		t.Errorf("archive() uploaded = %v", uploaded)
+	}
+	if _, ok := uploaded["/loadtests/perf/nightly/status.json"]; !ok {
+		t.Errorf("archive() did not upload the status, uploaded = %v", uploaded)
+	}
+}
+
+func TestTruncateArchive(t *testing.T) {
+	files := map[string][]byte{
+		archiveStatusFile: []byte("{}"),
+		"a.log":           []byte("one\ntwo\nthree\n"),
+		"b.log":           []byte("short\n"),
+	}
+
+	want := map[string]string{archiveStatusFile: "{}", "a.log": "three\n", "b.log": "short\n"}
+	if got := truncateArchive(files, 2+2*8); !reflect.DeepEqual(got, want) {
+		t.Errorf("truncateArchive() = %q, want %q", got, want)
+	}
+}
+
+func TestEnsureFinalizersArchive(t *testing.T) {
+	deleted := metav1.Now()
+	v := archiveLoadTest(&lt.Archive{ConfigMap: &lt.ArchiveConfigMap{}})
+	v.Finalizers = []string{ArchiveFinalizer}
+	v.DeletionTimestamp = &deleted
+
+	r := &LoadTestReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(testScheme()).WithObjects(v).Build(),
+		Recorder: record.NewFakeRecorder(10),
+	}
+
+	if result, err := r.ensureFinalizers(context.Background(), v, logr.Discard()); result == nil || err != nil {
+		t.Fatalf("ensureFinalizers() = %v, %v", result, err)
+	}
+	if controllerutil.ContainsFinalizer(v, ArchiveFinalizer) {
+		t.Errorf("ensureFinalizers() kept the archive finalizer")
+	}
+
+	archived := &corev1.ConfigMap{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: "nightly-archive", Namespace: "perf"}, archived); err != nil {
+		t.Errorf("ensureFinalizers() did not archive the LoadTest: %v", err)
+	}
+}
+
+// terminatingNamespaceClient forbids creating objects, as in a namespace being deleted.
+type terminatingNamespaceClient struct {
+	client.Client
+}
+
+func (c terminatingNamespaceClient) Create(_ context.Context, obj client.Object, _ ...client.CreateOption) error {
+	return errors.NewForbidden(corev1.Resource("configmaps"), obj.GetName(),
+		fmt.Errorf("unable to create new content in namespace %s because it is being terminated", obj.GetNamespace()))
+}
+
+func TestEnsureFinalizersArchiveForbidden(t *testing.T) {
+	deleted := metav1.Now()
+	v := archiveLoadTest(&lt.Archive{ConfigMap: &lt.ArchiveConfigMap{}})
+	v.Finalizers = []string{ArchiveFinalizer}
+	v.DeletionTimestamp = &deleted
+
+	recorder := record.NewFakeRecorder(10)
+	r := &LoadTestReconciler{
+		Client:   terminatingNamespaceClient{fake.NewClientBuilder().WithScheme(testScheme()).WithObjects(v).Build()},
+		Recorder: recorder,
+	}
+
+	if result, err := r.ensureFinalizers(context.Background(), v, logr.Discard()); result == nil || err != nil {
+		t.Fatalf("ensureFinalizers() = %v, %v, want a result and no error", result, err)
+	}
+	if controllerutil.ContainsFinalizer(v, ArchiveFinalizer) {
+		t.Errorf("ensureFinalizers() kept the archive finalizer")
+	}
+	if event := <-recorder.Events; !strings.HasPrefix(event, "Warning ArchiveSkipped") {
+		t.Errorf("ensureFinalizers() event = %q", event)
+	}
+}
+
+func TestEnsureFinalizersArchiveUnreachable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name          string
+		namespace     corev1.NamespacePhase
+		wantErr       bool
+		wantFinalizer bool
+		wantEvent     string
+	}{
+		{name: "retried", namespace: corev1.NamespaceActive, wantErr: true, wantFinalizer: true, wantEvent: "Warning ArchiveFailed"},
+		{name: "skipped in a terminating namespace", namespace: corev1.NamespaceTerminating, wantEvent: "Warning CleanupSkipped"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			deleted := metav1.Now()
+			v := archiveLoadTest(&lt.Archive{ObjectStorage: &lt.ArchiveObjectStorage{URL: server.URL}})
+			v.Finalizers = []string{ArchiveFinalizer}
+			v.DeletionTimestamp = &deleted
+			namespace := &corev1.Namespace{ObjectMeta: metav1.ObjectMeta{Name: v.Namespace}, Status: corev1.NamespaceStatus{Phase: tt.namespace}}
+
+			recorder := record.NewFakeRecorder(10)
+			r := &LoadTestReconciler{
+				Client:     fake.NewClientBuilder().WithScheme(testScheme()).WithObjects(v, namespace).Build(),
+				Recorder:   recorder,
+				HTTPClient: server.Client(),
+			}
+
+			if _, err := r.ensureFinalizers(context.Background(), v, logr.Discard()); (err != nil) != tt.wantErr {
+				t.Fatalf("ensureFinalizers() error = %v, want error %v", err, tt.wantErr)
+			}
+			if got := controllerutil.ContainsFinalizer(v, ArchiveFinalizer); got != tt.wantFinalizer {
+				t.Errorf("ensureFinalizers() archive finalizer = %v, want %v", got, tt.wantFinalizer)
+			}
+
+			var events []string
+			for len(recorder.Events) > 0 {
+				events = append(events, <-recorder.Events)
+			}
+			if len(events) == 0 || !strings.HasPrefix(events[len(events)-1], tt.wantEvent) {
+				t.Errorf("ensureFinalizers() events = %q, want %q last", events, tt.wantEvent)
+			}
+		})
+	}
+}
@@ -0,0 +1,212 @@
+/*
+ * Copyright (c) 2022.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0.
+ *
+ * If a copy of the MPL was not distributed with
+ * this file, You can obtain one at
+ *
+ *   http://mozilla.org/MPL/2.0/
+ */
+
+package controllers
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"strconv"
+
+	lt "github.com/artilleryio/artillery-operator/api/v1alpha1"
+	"gopkg.in/yaml.v3"
+	v1 "k8s.io/api/batch/v1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+// splitPhaseFields the load phase fields divided across workers when using the Split distribution.
+var splitPhaseFields = []string{"arrivalRate", "rampTo", "arrivalCount"}
+
+// workerCount returns the number of workers run by a LoadTest.
+func workerCount(v *lt.LoadTest) int {
+	if v.Spec.Count > 0 {
+		return v.Spec.Count
+	}
+	return 1
+}
+
+// isSplit returns whether the LoadTest's load phases are divided across workers.
+func isSplit(v *lt.LoadTest) bool {
+	return v.Spec.Distribution == lt.DistributionSplit
+}
+
+// workerScriptKey returns the snapshot key holding the test script run by the worker with the provided index.
+func workerScriptKey(index string, key string) string {
+	return fmt.Sprintf("worker-%s-%s", index, key)
+}
+
+// hasWorkerScripts returns whether every worker runs its own test script, generated from the LoadTest's test script.
+func hasWorkerScripts(v *lt.LoadTest) bool {
+	return isSplit(v) || publishesPrometheusMetrics(v)
+}
+
+// workerScripts creates the test script run by every worker, generated from the LoadTest's test script.
+// Load phases are divided across workers when using the Split distribution,
+// and the publish-metrics plugin is configured when publishing metrics to Prometheus.
+func workerScripts(v *lt.LoadTest, data string) ([]string, error) {
+	scripts := make([]string, workerCount(v))
+	for i := range scripts {
+		script, err := workerScript(v, data, i)
+		if err != nil {
+			return nil, err
+		}
+		scripts[i] = script
+	}
+	return scripts, nil
+}
+
+// workerScript creates the test script run by the worker with the provided index.
+func workerScript(v *lt.LoadTest, data string, index int) (string, error) {
+	var root yaml.Node
+	if err := yaml.Unmarshal([]byte(data), &root); err != nil {
+		return "", err
+	}
+	if len(root.Content) == 0 {
+		return "", scriptError{line: 1, message: "test script is empty"}
+	}
+
+	if isSplit(v) {
+		if err := splitPhases(root.Content[0], v.Spec.Environment, index, workerCount(v)); err != nil {
+			return "", err
+		}
+	}
+	if publishesPrometheusMetrics(v) {
+		if err := addPublishMetrics(root.Content[0], v); err != nil {
+			return "", err
+		}
+	}
+
+	var buf bytes.Buffer
+	enc := yaml.NewEncoder(&buf)
+	enc.SetIndent(2)
+	if err := enc.Encode(&root); err != nil {
+		return "", err
+	}
+	return buf.String(), nil
+}
+
+// splitPhases divides the arrivalRate, rampTo and arrivalCount of the load phases defined by the test script's
+// config and selected environment, keeping the share run by the worker with the provided index.
+// Integer values are divided with their remainder spread over the first workers, e.g. an arrivalRate of 10
+// split across 4 workers results in arrival rates of 3, 3, 2 and 2. A non-zero integer arrivalRate or
+// arrivalCount must be at least the worker count.
+func splitPhases(root *yaml.Node, environment string, index int, count int) error {
+	config := mappingValue(root, "config")
+	phases := []*yaml.Node{
+		mappingValue(config, "phases"),
+		mappingValue(mappingValue(mappingValue(config, "environments"), environment), "phases"),
+	}
+
+	for _, n := range phases {
+		if n == nil || n.Kind != yaml.SequenceNode {
+			continue
+		}
+		for _, phase := range n.Content {
+			for _, field := range splitPhaseFields {
+				value := mappingValue(phase, field)
+				if value == nil {
+					continue
+				}
+				if err := splitValue(value, field, index, count); err != nil {
+					return err
+				}
+			}
+		}
+	}
+
+	return nil
+}
+
+// splitValue replaces a load phase value with the share run by the worker with the provided index.
+// An arrivalRate or arrivalCount lower than the worker count is rejected, as some workers would run no load.
+func splitValue(n *yaml.Node, field string, index int, count int) error {
+	if i, err := strconv.ParseInt(n.Value, 10, 64); err == nil && n.Kind == yaml.ScalarNode {
+		if field != "rampTo" && i > 0 && i < int64(count) {
+			return scriptError{line: n.Line, message: fmt.Sprintf("%s %d cannot be split across %d workers, every worker must get at least 1", field, i, count)}
+		}
+		share := i / int64(count)
+		if int64(index) < i%int64(count) {
+			share++
+		}
+		n.Value = strconv.FormatInt(share, 10)
+		return nil
+	}
+
+	if f, err := strconv.ParseFloat(n.Value, 64); err == nil && n.Kind == yaml.ScalarNode {
+		n.Value = strconv.FormatFloat(f/float64(count), 'f', -1, 64)
+		return nil
+	}
+
+	return scriptError{line: n.Line, message: fmt.Sprintf("%s %q cannot be split across workers, it must be a number", field, n.Value)}
+}
+
+// workerScriptItems maps the test script file of every worker not storing its own test script in the snapshot,
+// to the key stored by the first worker running the same test script, see snapshotConfigMap.
+func workerScriptItems(v *lt.LoadTest, snapshot *core.ConfigMap) []core.KeyToPath {
+	key := testScriptKey(v)
+
+	var items []core.KeyToPath
+	stored := workerScriptKey("0", key)
+	for i := 0; i < workerCount(v); i++ {
+		path := workerScriptKey(strconv.Itoa(i), key)
+		if _, ok := snapshot.Data[path]; ok {
+			stored = path
+			continue
+		}
+		items = append(items, core.KeyToPath{Key: stored, Path: path})
+	}
+	return items
+}
+
+// mountWorkerScripts mounts every worker's test script from the LoadTest's snapshot, along with every snapshot key.
+// Workers sharing a test script read it from a single snapshot key, projected to each of their test script files.
+func (r *LoadTestReconciler) mountWorkerScripts(ctx context.Context, v *lt.LoadTest, job *v1.Job) error {
+	snapshot := &core.ConfigMap{}
+	if err := r.Get(ctx, types.NamespacedName{Name: snapshotConfigMapName(v), Namespace: v.Namespace}, snapshot); err != nil {
+		return err
+	}
+
+	items := workerScriptItems(v, snapshot)
+	if len(items) == 0 {
+		return nil
+	}
+
+	ref := core.LocalObjectReference{Name: snapshot.Name}
+	for i := range job.Spec.Template.Spec.Volumes {
+		volume := &job.Spec.Template.Spec.Volumes[i]
+		if volume.Name != TestScriptVol {
+			continue
+		}
+		volume.VolumeSource = core.VolumeSource{
+			Projected: &core.ProjectedVolumeSource{
+				Sources: []core.VolumeProjection{
+					{ConfigMap: &core.ConfigMapProjection{LocalObjectReference: ref}},
+					{ConfigMap: &core.ConfigMapProjection{LocalObjectReference: ref, Items: items}},
+				},
+			},
+		}
+	}
+	return nil
+}
+
+// validateWorkerScripts ensures the test script run by every worker can be generated from the LoadTest's test script.
+func validateWorkerScripts(v *lt.LoadTest, cm *core.ConfigMap) error {
+	if !hasWorkerScripts(v) {
+		return nil
+	}
+
+	// Worker test scripts are generated the same way regardless of the worker's index
+	_, err := workerScript(v, cm.Data[testScriptKey(v)], 0)
+	return err
+}
@@ -0,0 +1,137 @@
+/*
+ * Copyright (c) 2022.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0.
+ *
+ * If a copy of the MPL was not distributed with
+ * this file, You can obtain one at
+ *
+ *   http://mozilla.org/MPL/2.0/
+ */
+
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	lt "github.com/artilleryio/artillery-operator/api/v1alpha1"
+	"gopkg.in/yaml.v3"
+)
+
+const splitTestScriptFixture = `config:
+  target: "http://localhost:8080"
+  environments:
+    staging:
+      phases:
+        - duration: 60
+          arrivalRate: 5
+  phases:
+    - duration: 60
+      arrivalRate: 10
+      rampTo: 2
+    - duration: 30
+      arrivalCount: 6
+    - pause: 10
+    - duration: 60
+      arrivalRate: 0.5
+scenarios:
+  - flow:
+      - get:
+          url: "/"
+`
+
+func TestWorkerScriptsSplit(t *testing.T) {
+	type phase struct {
+		ArrivalRate  *float64 `yaml:"arrivalRate"`
+		RampTo       *float64 `yaml:"rampTo"`
+		ArrivalCount *float64 `yaml:"arrivalCount"`
+	}
+	type script struct {
+		Config struct {
+			Environments map[string]struct {
+				Phases []phase `yaml:"phases"`
+			} `yaml:"environments"`
+			Phases []phase `yaml:"phases"`
+		} `yaml:"config"`
+	}
+
+	v := &lt.LoadTest{Spec: lt.LoadTestSpec{Count: 4, Distribution: lt.DistributionSplit, Environment: "staging"}}
+	scripts, err := workerScripts(v, splitTestScriptFixture)
+	if err != nil {
+		t.Fatalf("workerScripts() error = %v", err)
+	}
+	if len(scripts) != 4 {
+		t.Fatalf("workerScripts() = %d scripts, want 4", len(scripts))
+	}
+
+	var got [][]float64
+	for _, data := range scripts {
+		var s script
+		if err := yaml.Unmarshal([]byte(data), &s); err != nil {
+			t.Fatalf("workerScripts() produced invalid YAML: %v", err)
+		}
+		p := s.Config.Phases
+		got = append(got, []float64{
+			*p[0].ArrivalRate, *p[0].RampTo, *p[1].ArrivalCount, *p[3].ArrivalRate,
+			*s.Config.Environments["staging"].Phases[0].ArrivalRate,
+		})
+	}
+
+	want := [][]float64{
+		{3, 1, 2, 0.125, 2},
+		{3, 1, 2, 0.125, 1},
+		{2, 0, 1, 0.125, 1},
+		{2, 0, 1, 0.125, 1},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("workerScripts() phases = %v, want %v", got, want)
+	}
+}
+
+func TestWorkerScriptsSplitErrors(t *testing.T) {
+	script := `config:
+  target: "http://localhost:8080"
+  phases:
+    - duration: 60
+      arrivalRate: "{{ rate }}"
+`
+	v := &lt.LoadTest{Spec: lt.LoadTestSpec{Count: 2, Distribution: lt.DistributionSplit}}
+	_, err := workerScripts(v, script)
+	want := `line 5: arrivalRate "{{ rate }}" cannot be split across workers, it must be a number`
+	if err == nil || err.Error() != want {
+		t.Errorf("workerScripts() error = %v, want %s", err, want)
+	}
+}
+
+func TestWorkerScriptsSplitBelowWorkerCount(t *testing.T) {
+	script := `config:
+  target: "http://localhost:8080"
+  phases:
+    - duration: 60
+      arrivalRate: 0
+    - duration: 60
+      arrivalCount: 1
+`
+	v := &lt.LoadTest{Spec: lt.LoadTestSpec{Count: 4, Distribution: lt.DistributionSplit}}
+	_, err := workerScripts(v, script)
+	want := `line 7: arrivalCount 1 cannot be split across 4 workers, every worker must get at least 1`
+	if err == nil || err.Error() != want {
+		t.Errorf("workerScripts() error = %v, want %s", err, want)
+	}
+
+	v.Spec.Count = 1
+	if _, err := workerScripts(v, script); err != nil {
+		t.Errorf("workerScripts() error = %v, want nil", err)
+	}
+}
+
+func TestSplitWorkerArgs(t *testing.T) {
+	v := &lt.LoadTest{Spec: lt.LoadTestSpec{Count: 4, Distribution: lt.DistributionSplit, Environment: "staging"}}
+
+	want := []string{"run", "/data/worker-$(WORKER_INDEX)-test-script.yaml", "--environment", "staging"}
+	if got := workerArgs(v); !reflect.DeepEqual(got, want) {
+		t.Errorf("workerArgs() = %q, want %q", got, want)
+	}
+}
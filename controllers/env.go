@@ -0,0 +1,178 @@
+/*
+ * Copyright (c) 2022.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0.
+ *
+ * If a copy of the MPL was not distributed with
+ * this file, You can obtain one at
+ *
+ *   http://mozilla.org/MPL/2.0/
+ */
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	lt "github.com/artilleryio/artillery-operator/api/v1alpha1"
+	"github.com/go-logr/logr"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// envSecretsRequeueAfter is how long until a LoadTest missing env var Secrets checks them again.
+const envSecretsRequeueAfter = 30 * time.Second
+
+// envRef references a Secret or ConfigMap required by a worker env var.
+// An empty key references the whole object, as used by envFrom.
+type envRef struct {
+	secret bool
+	name   string
+	key    string
+	field  string
+}
+
+// kind returns the kind of object referenced.
+func (e envRef) kind() string {
+	if e.secret {
+		return "Secret"
+	}
+	return "ConfigMap"
+}
+
+// envRefs returns the non-optional Secrets and ConfigMaps referenced
+// in the LoadTest Custom Resource's spec.env and spec.envFrom.
+func envRefs(v *lt.LoadTest) []envRef {
+	var refs []envRef
+
+	for _, env := range v.Spec.Env {
+		if env.ValueFrom == nil {
+			continue
+		}
+
+		field := fmt.Sprintf(".spec.env[%s]", env.Name)
+		if ref := env.ValueFrom.SecretKeyRef; ref != nil && !isOptional(ref.Optional) {
+			refs = append(refs, envRef{secret: true, name: ref.Name, key: ref.Key, field: field})
+		}
+		if ref := env.ValueFrom.ConfigMapKeyRef; ref != nil && !isOptional(ref.Optional) {
+			refs = append(refs, envRef{name: ref.Name, key: ref.Key, field: field})
+		}
+	}
+
+	for i, env := range v.Spec.EnvFrom {
+		field := fmt.Sprintf(".spec.envFrom[%d]", i)
+		if ref := env.SecretRef; ref != nil && !isOptional(ref.Optional) {
+			refs = append(refs, envRef{secret: true, name: ref.Name, field: field})
+		}
+		if ref := env.ConfigMapRef; ref != nil && !isOptional(ref.Optional) {
+			refs = append(refs, envRef{name: ref.Name, field: field})
+		}
+	}
+
+	return refs
+}
+
+func isOptional(optional *bool) bool {
+	return optional != nil && *optional
+}
+
+// hasKey returns whether a Secret or ConfigMap holds the provided key.
+func hasKey(obj client.Object, key string) bool {
+	switch o := obj.(type) {
+	case *core.Secret:
+		_, ok := o.Data[key]
+		return ok
+	case *core.ConfigMap:
+		_, ok := o.Data[key]
+		_, binaryOk := o.BinaryData[key]
+		return ok || binaryOk
+	default:
+		return false
+	}
+}
+
+// apiReader returns the reader used to get objects that are not cached, e.g. Secrets and Namespaces.
+func (r *LoadTestReconciler) apiReader() client.Reader {
+	if r.APIReader != nil {
+		return r.APIReader
+	}
+	return r.Client
+}
+
+// ensureEnvConfig ensures the Secrets and ConfigMaps referenced by worker env vars
+// are available on the cluster, along with any referenced keys.
+// If not, a Warning event is triggered for every missing reference.
+// These events are viewable when running: kubectl describe loadtest <loadtest-name>.
+func (r *LoadTestReconciler) ensureEnvConfig(ctx context.Context,
+	instance *lt.LoadTest,
+	logger logr.Logger) (*ctrl.Result, error) {
+	if launched(instance) {
+		// Workers already exist, later changes to referenced objects only affect restarted workers
+		return nil, nil
+	}
+
+	var (
+		missing        []string
+		missingSecrets bool
+	)
+
+	for _, ref := range envRefs(instance) {
+		var (
+			obj    client.Object = &core.ConfigMap{}
+			reader client.Reader = r.Client
+		)
+		if ref.secret {
+			obj, reader = &core.Secret{}, r.apiReader()
+		}
+
+		err := reader.Get(ctx, types.NamespacedName{
+			Name:      ref.name,
+			Namespace: instance.Namespace,
+		}, obj)
+
+		switch {
+		case err != nil && errors.IsNotFound(err):
+			logger.Error(err, "Env var "+ref.kind()+" is missing", ref.kind(), ref.name, "Field", ref.field)
+			r.Recorder.Eventf(instance, "Warning", "Missing"+ref.kind(), "Load Test env var %s %s is missing, see field %s", ref.kind(), ref.name, ref.field)
+
+			missing = append(missing, ref.kind()+" "+ref.name)
+			missingSecrets = missingSecrets || ref.secret
+
+		case err != nil:
+			logger.Error(err, "Failed to get "+ref.kind(), ref.kind(), ref.name, "Field", ref.field)
+			return &ctrl.Result{}, err
+
+		case ref.key != "" && !hasKey(obj, ref.key):
+			logger.Info("Env var "+ref.kind()+" key is missing", ref.kind(), ref.name, "Key", ref.key, "Field", ref.field)
+			r.Recorder.Eventf(instance, "Warning", "Missing"+ref.kind()+"Key", "Load Test env var %s %s has no key %s, see field %s", ref.kind(), ref.name, ref.key, ref.field)
+
+			missing = append(missing, fmt.Sprintf("%s %s key %s", ref.kind(), ref.name, ref.key))
+			missingSecrets = missingSecrets || ref.secret
+		}
+	}
+
+	if len(missing) > 0 {
+		msg := fmt.Sprintf("Load Test env var references are missing: %s", strings.Join(missing, ", "))
+		if err := r.failLoadTest(ctx, instance, ReasonMissingEnvConfig, msg); err != nil {
+			logger.Error(err, "Failed to update LoadTest status")
+			return &ctrl.Result{}, err
+		}
+
+		if missingSecrets {
+			// Secrets aren't watched, check them again later
+			return &ctrl.Result{RequeueAfter: envSecretsRequeueAfter}, nil
+		}
+		// Env ConfigMaps are watched, the LoadTest is requeued once they're created or updated
+		return &ctrl.Result{}, nil
+	}
+
+	// Secrets and ConfigMaps located
+	return nil, nil
+}
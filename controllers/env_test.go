@@ -0,0 +1,159 @@
+/*
+ * Copyright (c) 2022.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0.
+ *
+ * If a copy of the MPL was not distributed with
+ * this file, You can obtain one at
+ *
+ *   http://mozilla.org/MPL/2.0/
+ */
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+
+	lt "github.com/artilleryio/artillery-operator/api/v1alpha1"
+	"github.com/go-logr/logr"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func secretKeyEnv(name, secret, key string, optional bool) core.EnvVar {
+	return core.EnvVar{Name: name, ValueFrom: &core.EnvVarSource{SecretKeyRef: &core.SecretKeySelector{
+		LocalObjectReference: core.LocalObjectReference{Name: secret},
+		Key:                  key,
+		Optional:             &optional,
+	}}}
+}
+
+func configMapKeyEnv(name, configMap, key string, optional bool) core.EnvVar {
+	return core.EnvVar{Name: name, ValueFrom: &core.EnvVarSource{ConfigMapKeyRef: &core.ConfigMapKeySelector{
+		LocalObjectReference: core.LocalObjectReference{Name: configMap},
+		Key:                  key,
+		Optional:             &optional,
+	}}}
+}
+
+func TestEnvRefs(t *testing.T) {
+	optional := true
+	v := &lt.LoadTest{Spec: lt.LoadTestSpec{
+		Env: []core.EnvVar{
+			{Name: "REGION", Value: "eu-west-1"},
+			secretKeyEnv("API_TOKEN", "api", "token", false),
+			secretKeyEnv("DEBUG_TOKEN", "debug", "token", true),
+			configMapKeyEnv("TARGET", "settings", "target", false),
+		},
+		EnvFrom: []core.EnvFromSource{
+			{SecretRef: &core.SecretEnvSource{LocalObjectReference: core.LocalObjectReference{Name: "credentials"}}},
+			{ConfigMapRef: &core.ConfigMapEnvSource{LocalObjectReference: core.LocalObjectReference{Name: "extra"}, Optional: &optional}},
+		},
+	}}
+
+	want := []envRef{
+		{secret: true, name: "api", key: "token", field: ".spec.env[API_TOKEN]"},
+		{name: "settings", key: "target", field: ".spec.env[TARGET]"},
+		{secret: true, name: "credentials", field: ".spec.envFrom[0]"},
+	}
+	if got := envRefs(v); !reflect.DeepEqual(got, want) {
+		t.Errorf("envRefs() = %+v, want %+v", got, want)
+	}
+}
+
+func TestHasKey(t *testing.T) {
+	tests := []struct {
+		name string
+		obj  client.Object
+		key  string
+		want bool
+	}{
+		{name: "secret data", obj: &core.Secret{Data: map[string][]byte{"token": []byte("t")}}, key: "token", want: true},
+		{name: "secret string data is not stored", obj: &core.Secret{StringData: map[string]string{"token": "t"}}, key: "token"},
+		{name: "configmap data", obj: &core.ConfigMap{Data: map[string]string{"target": "t"}}, key: "target", want: true},
+		{name: "configmap binary data", obj: &core.ConfigMap{BinaryData: map[string][]byte{"cert": {0x30}}}, key: "cert", want: true},
+		{name: "missing key", obj: &core.ConfigMap{Data: map[string]string{"target": "t"}}, key: "region"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := hasKey(tt.obj, tt.key); got != tt.want {
+				t.Errorf("hasKey() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnsureEnvConfig(t *testing.T) {
+	api := &core.Secret{
+		ObjectMeta: metav1.ObjectMeta{Name: "api", Namespace: "default"},
+		Data:       map[string][]byte{"token": []byte("t")},
+	}
+	settings := &core.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "settings", Namespace: "default"},
+		BinaryData: map[string][]byte{"cert": {0x30}},
+	}
+
+	tests := []struct {
+		name        string
+		env         []core.EnvVar
+		wantFailed  bool
+		wantRequeue bool
+	}{
+		{
+			name: "references found",
+			env:  []core.EnvVar{secretKeyEnv("API_TOKEN", "api", "token", false), configMapKeyEnv("CERT", "settings", "cert", false)},
+		},
+		{
+			name: "optional references are not verified",
+			env:  []core.EnvVar{secretKeyEnv("DEBUG_TOKEN", "debug", "token", true)},
+		},
+		{
+			name:        "missing secret",
+			env:         []core.EnvVar{secretKeyEnv("DEBUG_TOKEN", "debug", "token", false)},
+			wantFailed:  true,
+			wantRequeue: true,
+		},
+		{
+			name:       "missing key",
+			env:        []core.EnvVar{configMapKeyEnv("TARGET", "settings", "target", false)},
+			wantFailed: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &lt.LoadTest{
+				ObjectMeta: metav1.ObjectMeta{Name: "basic-test", Namespace: "default"},
+				Spec:       lt.LoadTestSpec{Env: tt.env},
+			}
+			// Secrets are only available to the uncached reader
+			r := &LoadTestReconciler{
+				Client:    fake.NewClientBuilder().WithScheme(testScheme()).WithObjects(v, settings).Build(),
+				APIReader: fake.NewClientBuilder().WithScheme(testScheme()).WithObjects(api).Build(),
+				Recorder:  record.NewFakeRecorder(10),
+			}
+
+			result, err := r.ensureEnvConfig(context.Background(), v, logr.Discard())
+			if err != nil || (result != nil) != tt.wantFailed {
+				t.Fatalf("ensureEnvConfig() = %v, %v, want result %v and no error", result, err, tt.wantFailed)
+			}
+
+			// Missing Secrets are checked again, missing ConfigMaps are watched
+			if result != nil && (result.RequeueAfter > 0) != tt.wantRequeue {
+				t.Errorf("ensureEnvConfig() RequeueAfter = %v, want requeue %v", result.RequeueAfter, tt.wantRequeue)
+			}
+
+			failed := conditionsMap(v.Status.Conditions)[lt.LoadTestFailed]
+			if got := failed.Reason == ReasonMissingEnvConfig; got != tt.wantFailed {
+				t.Errorf("ensureEnvConfig() Failed reason = %q, want failed %v", failed.Reason, tt.wantFailed)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+/*
+ * Copyright (c) 2022.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0.
+ *
+ * If a copy of the MPL was not distributed with
+ * this file, You can obtain one at
+ *
+ *   http://mozilla.org/MPL/2.0/
+ */
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	lt "github.com/artilleryio/artillery-operator/api/v1alpha1"
+	"github.com/go-logr/logr"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// finalizeTimeout bounds how long a deleted LoadTest's failed cleanup is retried, before it's skipped.
+const finalizeTimeout = 10 * time.Minute
+
+// finalizer runs cleanup that must not be missed when a LoadTest is deleted.
+type finalizer struct {
+	name string
+	// needed checks if a LoadTest requires the finalizer.
+	needed func(v *lt.LoadTest) bool
+	// finalize cleans up before the finalizer is removed.
+	finalize func(ctx context.Context, v *lt.LoadTest, logger logr.Logger) error
+}
+
+// finalizers lists the LoadTest finalizers in the order they run on deletion.
+// Worker logs are archived first, as later cleanup doesn't need the workers.
+func (r *LoadTestReconciler) finalizers() []finalizer {
+	return []finalizer{
+		{name: ArchiveFinalizer, needed: archives, finalize: r.finalizeArchive},
+		{name: PushgatewayFinalizer, needed: publishesPrometheusMetrics, finalize: r.finalizeMetrics},
+	}
+}
+
+// ensureFinalizers adds the finalizers a LoadTest needs while it exists, and runs their cleanup once it is deleted.
+// Every finalizer is removed once its cleanup succeeds, a failed cleanup is retried and keeps the LoadTest around,
+// unless its namespace is being deleted or it has been deleted for longer than finalizeTimeout.
+func (r *LoadTestReconciler) ensureFinalizers(ctx context.Context,
+	instance *lt.LoadTest,
+	logger logr.Logger) (*ctrl.Result, error) {
+	if !instance.DeletionTimestamp.IsZero() {
+		for _, f := range r.finalizers() {
+			if !controllerutil.ContainsFinalizer(instance, f.name) {
+				continue
+			}
+
+			if err := f.finalize(ctx, instance, logger); err != nil {
+				reason, skip, skipErr := r.skipCleanup(ctx, instance, time.Now())
+				if skipErr != nil {
+					logger.Error(skipErr, "Failed to get LoadTest namespace")
+				}
+				if !skip {
+					return &ctrl.Result{}, err
+				}
+
+				logger.Error(err, "Skipping LoadTest cleanup", "Finalizer", f.name, "Reason", reason)
+				r.Recorder.Eventf(instance, "Warning", "CleanupSkipped", "Skipped %s cleanup, %s: %s", f.name, reason, err)
+			}
+
+			controllerutil.RemoveFinalizer(instance, f.name)
+			if err := r.Update(ctx, instance); err != nil {
+				logger.Error(err, "Failed to remove LoadTest finalizer", "Finalizer", f.name)
+				return &ctrl.Result{}, err
+			}
+		}
+		return &ctrl.Result{}, nil
+	}
+
+	for _, f := range r.finalizers() {
+		if !f.needed(instance) || controllerutil.ContainsFinalizer(instance, f.name) {
+			continue
+		}
+
+		controllerutil.AddFinalizer(instance, f.name)
+		if err := r.Update(ctx, instance); err != nil {
+			logger.Error(err, "Failed to add LoadTest finalizer", "Finalizer", f.name)
+			return &ctrl.Result{}, err
+		}
+	}
+
+	return nil, nil
+}
+
+// skipCleanup returns why a deleted LoadTest's failed cleanup must be skipped rather than retried:
+// its namespace is being deleted, e.g. along with the Pushgateway or object storage it cleans up,
+// or its cleanup has been failing for longer than finalizeTimeout.
+func (r *LoadTestReconciler) skipCleanup(ctx context.Context, v *lt.LoadTest, now time.Time) (string, bool, error) {
+	if now.Sub(v.DeletionTimestamp.Time) > finalizeTimeout {
+		return fmt.Sprintf("deleted for more than %s", finalizeTimeout), true, nil
+	}
+
+	ns := &core.Namespace{}
+	if err := r.apiReader().Get(ctx, types.NamespacedName{Name: v.Namespace}, ns); err != nil {
+		return "", false, client.IgnoreNotFound(err)
+	}
+	if !ns.DeletionTimestamp.IsZero() || ns.Status.Phase == core.NamespaceTerminating {
+		return fmt.Sprintf("namespace %s is being deleted", v.Namespace), true, nil
+	}
+	return "", false, nil
+}
+
+// finalizeArchive archives a deleted LoadTest's worker logs and final status.
+// Archiving is skipped if the API server forbids creating the archive, e.g. in a terminating namespace.
+// Other failures, e.g. to upload to object storage, are retried until ensureFinalizers skips them.
+func (r *LoadTestReconciler) finalizeArchive(ctx context.Context, v *lt.LoadTest, logger logr.Logger) error {
+	if !archives(v) {
+		return nil
+	}
+
+	err := r.archive(ctx, v, logger)
+	if errors.IsForbidden(err) {
+		// E.g. the namespace is being deleted, retrying would keep it and the LoadTest terminating
+		logger.Error(err, "Skipping LoadTest archive, the archive cannot be created")
+		r.Recorder.Eventf(v, "Warning", "ArchiveSkipped", "Skipped archiving Load Test worker logs and status: %s", err)
+		return nil
+	}
+	if err != nil {
+		logger.Error(err, "Failed to archive LoadTest")
+		r.Recorder.Eventf(v, "Warning", "ArchiveFailed", "Failed to archive Load Test worker logs and status: %s", err)
+		return err
+	}
+	return nil
+}
+
+// finalizeMetrics deletes a deleted LoadTest's published metrics from the Pushgateway.
+func (r *LoadTestReconciler) finalizeMetrics(ctx context.Context, v *lt.LoadTest, logger logr.Logger) error {
+	if !publishesPrometheusMetrics(v) || v.Status.MetricsDeletionTime != nil {
+		return nil
+	}
+
+	logger.Info("Deleting LoadTest metrics from Pushgateway", "Pushgateway", v.Spec.Metrics.Prometheus.Pushgateway)
+	if err := r.deletePushgatewayMetrics(ctx, v); err != nil {
+		logger.Error(err, "Failed to delete metrics from Pushgateway")
+		r.Recorder.Eventf(v, "Warning", "MetricsCleanupFailed", "Failed to delete Load Test metrics from Pushgateway: %s", err)
+		return err
+	}
+	return nil
+}
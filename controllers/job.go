@@ -15,6 +15,9 @@ package controllers
 //goland:noinspection SpellCheckingInspection
 import (
 	"context"
+	"fmt"
+	"strconv"
+	"time"
 
 	lt "github.com/artilleryio/artillery-operator/api/v1alpha1"
 	"github.com/go-logr/logr"
@@ -42,7 +45,18 @@ func (r *LoadTestReconciler) ensureJob(
 		Namespace: instance.Namespace,
 	}, found)
 
-	if err != nil && errors.IsNotFound(err) {
+	if err != nil && errors.IsNotFound(err) && workersSeen(instance) {
+		// Never recreate the Job of a started or finished LoadTest, that would run the load test again
+		logger.Info("Job of started LoadTest not found, not recreating it", "Job.Namespace", job.Namespace, "Job.Name", job.Name)
+		return &ctrl.Result{RequeueAfter: r.finishedRequeueAfter(instance, time.Now())}, nil
+	} else if err != nil && errors.IsNotFound(err) {
+		if hasWorkerScripts(instance) {
+			if err := r.mountWorkerScripts(ctx, instance, job); err != nil {
+				logger.Error(err, "Failed to mount worker test scripts", "Job.Namespace", job.Namespace, "Job.Name", job.Name)
+				return &ctrl.Result{}, err
+			}
+		}
+
 		// Create a new job
 		logger.Info("Creating a new Job", "Job.Namespace", job.Namespace, "Job.Name", job.Name)
 
@@ -62,23 +76,43 @@ func (r *LoadTestReconciler) ensureJob(
 		return &ctrl.Result{}, err
 	}
 
+	if suspend := boolValue(instance.Spec.Suspend); boolValue(found.Spec.Suspend) != suspend {
+		found.Spec.Suspend = &suspend
+		if err := r.Update(ctx, found); err != nil {
+			logger.Error(err, "Failed to update Job suspension", "Job.Namespace", found.Namespace, "Job.Name", found.Name)
+			return &ctrl.Result{}, err
+		}
+
+		manager := fieldManager(instance, "f:spec", "f:suspend")
+		if suspend {
+			r.Recorder.Eventf(instance, "Normal", "Suspended", "Load Test suspended by %s", manager)
+		} else {
+			r.Recorder.Eventf(instance, "Normal", "Resumed", "Load Test resumed by %s", manager)
+		}
+	}
+
 	// job found successfully
 	return nil, nil
 }
 
+// workersSeen returns whether the LoadTest was launched and its workers were seen, whether pending, running or finished.
+func workersSeen(v *lt.LoadTest) bool {
+	return launched(v) && (v.Status.StartTime != nil || v.Status.CompletionTime != nil || len(v.Status.Workers) > 0)
+}
+
+func boolValue(b *bool) bool {
+	return b != nil && *b
+}
+
 // job creates a Job spec based on the LoadTest Custom Resource.
 func (r *LoadTestReconciler) job(v *lt.LoadTest) *v1.Job {
 	var (
-		parallelism  int32 = 1
-		completions  int32 = 1
-		backoffLimit int32 = 0
+		parallelism    = int32(workerCount(v))
+		completions    = parallelism
+		backoffLimit   = int32(0)
+		completionMode = v1.IndexedCompletion
 	)
 
-	if v.Spec.Count > 0 {
-		parallelism = int32(v.Spec.Count)
-		completions = int32(v.Spec.Count)
-	}
-
 	job := &v1.Job{
 		ObjectMeta: metav1.ObjectMeta{
 			Name:      v.Name,
@@ -86,9 +120,12 @@ func (r *LoadTestReconciler) job(v *lt.LoadTest) *v1.Job {
 			Labels:    labels(v, "loadtest-worker-master"),
 		},
 		Spec: v1.JobSpec{
-			Parallelism:  &parallelism,
-			Completions:  &completions,
-			BackoffLimit: &backoffLimit,
+			Parallelism:           &parallelism,
+			Completions:           &completions,
+			BackoffLimit:          &backoffLimit,
+			CompletionMode:        &completionMode,
+			ActiveDeadlineSeconds: v.Spec.ActiveDeadlineSeconds,
+			Suspend:               v.Spec.Suspend,
 			Template: corev1.PodTemplateSpec{
 				ObjectMeta: metav1.ObjectMeta{
 					Labels: labels(v, "loadtest-worker"),
@@ -98,59 +135,172 @@ func (r *LoadTestReconciler) job(v *lt.LoadTest) *v1.Job {
 					Containers: []corev1.Container{
 						{
 							Name:            v.Name,
-							Image:           WorkerImage,
-							ImagePullPolicy: corev1.PullAlways,
+							Image:           r.workerImage(v),
+							ImagePullPolicy: v.Spec.ImagePullPolicy,
 							VolumeMounts: []corev1.VolumeMount{
 								{
 									Name:      TestScriptVol,
 									MountPath: "/data",
 								},
 							},
-							Args: []string{
-								"run",
-								"/data/" + TestScriptFilename,
-							},
-							Env: append(
-								[]corev1.EnvVar{
-									// published metrics use WORKER_ID to connect the pod (worker) to a Pushgateway JobID
-									// Uses the downward API:
-									// https://kubernetes.io/docs/tasks/inject-data-application/downward-api-volume-expose-pod-information/#the-downward-api
-									{
-										Name: "WORKER_ID",
-										ValueFrom: &corev1.EnvVarSource{
-											FieldRef: &corev1.ObjectFieldSelector{
-												FieldPath: "metadata.name",
-											},
-										},
-									},
-								},
-								r.TelemetryConfig.ToK8sEnvVar()...,
-							),
+							Args:    workerArgs(v),
+							EnvFrom: v.Spec.EnvFrom,
+							Env:     r.workerEnv(v),
 						},
 					},
-					// Provides access to the ConfigMap holding the test script config
+					// Provides access to the snapshot of the test script
+					// and any external payload or processor files.
 					Volumes: []corev1.Volume{
 						{
 							Name: TestScriptVol,
 							VolumeSource: corev1.VolumeSource{
 								ConfigMap: &corev1.ConfigMapVolumeSource{
 									LocalObjectReference: corev1.LocalObjectReference{
-										Name: v.Spec.TestScript.Config.ConfigMap,
+										Name: snapshotConfigMapName(v),
 									},
 								},
 							},
 						},
 					},
-					RestartPolicy: "Never",
+					ImagePullSecrets: v.Spec.ImagePullSecrets,
+					RestartPolicy:    "Never",
 				},
 			},
 		},
 	}
 
+	applyWorker(&job.Spec.Template, v.Spec.Worker)
+
 	_ = ctrl.SetControllerReference(v, job, r.Scheme)
 	return job
 }
 
+// applyWorker merges the LoadTest's worker settings over the generated worker pod template.
+// Labels generated by the operator take precedence, as they're used to select workers.
+func applyWorker(template *corev1.PodTemplateSpec, w *lt.Worker) {
+	if w == nil {
+		return
+	}
+
+	template.Labels = mergeMaps(w.Labels, template.Labels)
+	template.Annotations = mergeMaps(template.Annotations, w.Annotations)
+
+	spec := &template.Spec
+	if len(w.NodeSelector) > 0 {
+		spec.NodeSelector = w.NodeSelector
+	}
+	if len(w.Tolerations) > 0 {
+		spec.Tolerations = w.Tolerations
+	}
+	if w.Affinity != nil {
+		spec.Affinity = w.Affinity
+	}
+	if len(w.TopologySpreadConstraints) > 0 {
+		spec.TopologySpreadConstraints = w.TopologySpreadConstraints
+	}
+	if w.ServiceAccountName != "" {
+		spec.ServiceAccountName = w.ServiceAccountName
+	}
+	if w.SecurityContext != nil {
+		spec.SecurityContext = w.SecurityContext
+	}
+
+	for i := range spec.Containers {
+		container := &spec.Containers[i]
+		container.Resources = w.Resources
+		if w.ContainerSecurityContext != nil {
+			container.SecurityContext = w.ContainerSecurityContext
+		}
+	}
+}
+
+// mergeMaps returns a new map with the entries of all provided maps.
+// Entries in later maps take precedence.
+func mergeMaps(maps ...map[string]string) map[string]string {
+	out := map[string]string{}
+	for _, m := range maps {
+		for k, v := range m {
+			out[k] = v
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return out
+}
+
+// workerImage returns the Artillery image used by the LoadTest's workers.
+// The LoadTest's spec.image takes precedence over the operator-wide worker image.
+func (r *LoadTestReconciler) workerImage(v *lt.LoadTest) string {
+	switch {
+	case v.Spec.Image != "":
+		return v.Spec.Image
+	case r.WorkerImage != "":
+		return r.WorkerImage
+	default:
+		return DefaultWorkerImage
+	}
+}
+
+// workerEnv creates the env vars set in every worker.
+// Env vars managed by the operator are listed last, so they take precedence over the LoadTest's spec.env.
+func (r *LoadTestReconciler) workerEnv(v *lt.LoadTest) []corev1.EnvVar {
+	env := make([]corev1.EnvVar, 0, len(v.Spec.Env))
+	env = append(env, v.Spec.Env...)
+
+	env = append(env,
+		// Workers run as an Indexed Job, every worker's index is read from its completion index annotation.
+		// Uses the downward API:
+		// https://kubernetes.io/docs/tasks/inject-data-application/downward-api-volume-expose-pod-information/#the-downward-api
+		corev1.EnvVar{
+			Name: "WORKER_INDEX",
+			ValueFrom: &corev1.EnvVarSource{
+				FieldRef: &corev1.ObjectFieldSelector{
+					FieldPath: fmt.Sprintf("metadata.annotations['%s']", v1.JobCompletionIndexAnnotation),
+				},
+			},
+		},
+		corev1.EnvVar{
+			Name:  "WORKER_COUNT",
+			Value: strconv.Itoa(workerCount(v)),
+		},
+		// published metrics use WORKER_ID to connect the worker to a Pushgateway JobID.
+		// It's derived from the worker's index, so a retried worker keeps its JobID.
+		corev1.EnvVar{
+			Name:  "WORKER_ID",
+			Value: workerID(v, "$(WORKER_INDEX)"),
+		},
+	)
+
+	return append(env, r.TelemetryConfig.ToK8sEnvVar()...)
+}
+
+// workerID returns the ID of the worker with the provided index, e.g. basic-test-0.
+func workerID(v *lt.LoadTest, index string) string {
+	return fmt.Sprintf("%s-%s", v.Name, index)
+}
+
+// workerArgs creates the Artillery CLI arguments used by workers to run a test script.
+// When workers run their own test script, e.g. when load phases are split across workers,
+// every worker selects its test script using its index.
+func workerArgs(v *lt.LoadTest) []string {
+	script := testScriptKey(v)
+	if hasWorkerScripts(v) {
+		script = workerScriptKey("$(WORKER_INDEX)", script)
+	}
+
+	args := []string{
+		"run",
+		"/data/" + script,
+	}
+
+	if v.Spec.Environment != "" {
+		args = append(args, "--environment", v.Spec.Environment)
+	}
+
+	return args
+}
+
 // labels creates K8s labels used to organize
 // and categorize (scope and select) Load Test objects.
 func labels(v *lt.LoadTest, component string) map[string]string {
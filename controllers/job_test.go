@@ -0,0 +1,211 @@
+/*
+ * Copyright (c) 2022.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0.
+ *
+ * If a copy of the MPL was not distributed with
+ * this file, You can obtain one at
+ *
+ *   http://mozilla.org/MPL/2.0/
+ */
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	lt "github.com/artilleryio/artillery-operator/api/v1alpha1"
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/resource"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/apimachinery/pkg/types"
+	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestWorkerEnv(t *testing.T) {
+	r := &LoadTestReconciler{}
+	v := &lt.LoadTest{
+		ObjectMeta: metav1.ObjectMeta{Name: "basic-test"},
+		Spec: lt.LoadTestSpec{
+			Count: 4,
+			Env:   []corev1.EnvVar{{Name: "WORKER_ID", Value: "overridden"}},
+		},
+	}
+
+	env := map[string]corev1.EnvVar{}
+	for _, e := range r.workerEnv(v) {
+		// Later env vars take precedence
+		env[e.Name] = e
+	}
+
+	if got := env["WORKER_INDEX"].ValueFrom.FieldRef.FieldPath; got != "metadata.annotations['batch.kubernetes.io/job-completion-index']" {
+		t.Errorf("WORKER_INDEX field path = %q", got)
+	}
+	if got := env["WORKER_COUNT"].Value; got != "4" {
+		t.Errorf("WORKER_COUNT = %q, want %q", got, "4")
+	}
+	if got := env["WORKER_ID"].Value; got != "basic-test-$(WORKER_INDEX)" {
+		t.Errorf("WORKER_ID = %q, want %q", got, "basic-test-$(WORKER_INDEX)")
+	}
+}
+
+func TestJobCompletionMode(t *testing.T) {
+	r := &LoadTestReconciler{Scheme: testScheme()}
+	job := r.job(&lt.LoadTest{ObjectMeta: metav1.ObjectMeta{Name: "basic-test"}})
+
+	if mode := job.Spec.CompletionMode; mode == nil || *mode != v1.IndexedCompletion {
+		t.Errorf("job() completion mode = %v, want Indexed", mode)
+	}
+	if got := *job.Spec.Completions; got != 1 {
+		t.Errorf("job() completions = %d, want 1", got)
+	}
+}
+
+func testScheme() *runtime.Scheme {
+	scheme := runtime.NewScheme()
+	_ = clientgoscheme.AddToScheme(scheme)
+	_ = lt.AddToScheme(scheme)
+	return scheme
+}
+
+func TestApplyWorker(t *testing.T) {
+	r := &LoadTestReconciler{Scheme: testScheme()}
+	v := &lt.LoadTest{
+		ObjectMeta: metav1.ObjectMeta{Name: "basic-test", Namespace: "default", UID: "uid"},
+		Spec:       lt.LoadTestSpec{Count: 2},
+	}
+	want := r.job(v).Spec.Template
+
+	t.Run("empty worker", func(t *testing.T) {
+		template := *want.DeepCopy()
+		applyWorker(&template, &lt.Worker{})
+		if !reflect.DeepEqual(template, want) {
+			t.Errorf("applyWorker() changed the template:\n got %+v\nwant %+v", template, want)
+		}
+	})
+
+	t.Run("scheduling and resources", func(t *testing.T) {
+		worker := &lt.Worker{
+			Resources: corev1.ResourceRequirements{
+				Requests: corev1.ResourceList{corev1.ResourceCPU: resource.MustParse("500m")},
+				Limits:   corev1.ResourceList{corev1.ResourceMemory: resource.MustParse("1Gi")},
+			},
+			NodeSelector: map[string]string{"pool": "load-testing"},
+			Tolerations: []corev1.Toleration{{
+				Key: "dedicated", Operator: corev1.TolerationOpEqual, Value: "load-testing", Effect: corev1.TaintEffectNoSchedule,
+			}},
+			Affinity: &corev1.Affinity{PodAntiAffinity: &corev1.PodAntiAffinity{
+				PreferredDuringSchedulingIgnoredDuringExecution: []corev1.WeightedPodAffinityTerm{{
+					Weight: 100,
+					PodAffinityTerm: corev1.PodAffinityTerm{
+						TopologyKey:   "kubernetes.io/hostname",
+						LabelSelector: &metav1.LabelSelector{MatchLabels: map[string]string{"artillery.io/test-name": "basic-test"}},
+					},
+				}},
+			}},
+			Labels: map[string]string{"team": "checkout", "artillery.io/test-name": "overridden"},
+		}
+
+		v := v.DeepCopy()
+		v.Spec.Worker = worker
+		template := r.job(v).Spec.Template
+
+		if !reflect.DeepEqual(template.Spec.Containers[0].Resources, worker.Resources) {
+			t.Errorf("resources = %+v, want %+v", template.Spec.Containers[0].Resources, worker.Resources)
+		}
+		if !reflect.DeepEqual(template.Spec.NodeSelector, worker.NodeSelector) {
+			t.Errorf("nodeSelector = %v, want %v", template.Spec.NodeSelector, worker.NodeSelector)
+		}
+		if !reflect.DeepEqual(template.Spec.Tolerations, worker.Tolerations) {
+			t.Errorf("tolerations = %v, want %v", template.Spec.Tolerations, worker.Tolerations)
+		}
+		if !reflect.DeepEqual(template.Spec.Affinity, worker.Affinity) {
+			t.Errorf("affinity = %v, want %v", template.Spec.Affinity, worker.Affinity)
+		}
+		// Generated labels select workers and take precedence
+		if template.Labels["team"] != "checkout" || template.Labels["artillery.io/test-name"] != "basic-test" {
+			t.Errorf("labels = %v", template.Labels)
+		}
+	})
+}
+
+func TestEnsureJobNotRecreated(t *testing.T) {
+	started := metav1.NewTime(time.Now().Add(-time.Minute))
+	snapshot := &lt.LoadTestScriptStatus{Snapshot: &lt.LoadTestSnapshotStatus{ConfigMap: "basic-test-snapshot", Hash: "sha256:abc"}}
+
+	tests := []struct {
+		name   string
+		status lt.LoadTestStatus
+		want   bool
+	}{
+		{name: "not launched", want: true},
+		{name: "snapshot taken", status: lt.LoadTestStatus{TestScript: snapshot}, want: true},
+		{name: "workers pending", status: lt.LoadTestStatus{TestScript: snapshot, Workers: []lt.LoadTestWorker{{Name: "basic-test-0-abcde"}}}},
+		{name: "started", status: lt.LoadTestStatus{TestScript: snapshot, StartTime: &started}},
+		{name: "finished", status: lt.LoadTestStatus{CompletionTime: &started}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			v := &lt.LoadTest{
+				ObjectMeta: metav1.ObjectMeta{Name: "basic-test", Namespace: "default", UID: "uid"},
+				Status:     tt.status,
+			}
+			r := &LoadTestReconciler{Scheme: testScheme(), Recorder: record.NewFakeRecorder(10)}
+			r.Client = fake.NewClientBuilder().WithScheme(r.Scheme).WithObjects(v).Build()
+
+			if _, err := r.ensureJob(ctx, v, logr.Discard(), r.job(v)); err != nil {
+				t.Fatalf("ensureJob() error = %v", err)
+			}
+
+			err := r.Get(ctx, types.NamespacedName{Name: v.Name, Namespace: v.Namespace}, &v1.Job{})
+			if created := err == nil; created != tt.want {
+				t.Errorf("ensureJob() created Job = %v, want %v", created, tt.want)
+			}
+		})
+	}
+}
+
+func TestMountWorkerScripts(t *testing.T) {
+	v := &lt.LoadTest{
+		ObjectMeta: metav1.ObjectMeta{Name: "basic-test", Namespace: "default", UID: "uid"},
+		Spec:       lt.LoadTestSpec{Count: 3, Metrics: &lt.Metrics{Prometheus: &lt.PrometheusMetrics{Pushgateway: "http://pushgateway:9091"}}},
+	}
+	snapshot, err := snapshotConfigMap(v, []*corev1.ConfigMap{scriptConfigMap(validTestScript)})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := &LoadTestReconciler{Scheme: testScheme()}
+	r.Client = fake.NewClientBuilder().WithScheme(r.Scheme).WithObjects(snapshot).Build()
+
+	job := r.job(v)
+	if err := r.mountWorkerScripts(context.Background(), v, job); err != nil {
+		t.Fatalf("mountWorkerScripts() error = %v", err)
+	}
+
+	projected := job.Spec.Template.Spec.Volumes[0].Projected
+	if projected == nil || len(projected.Sources) != 2 {
+		t.Fatalf("mountWorkerScripts() volume = %+v, want a projected volume with 2 sources", job.Spec.Template.Spec.Volumes[0])
+	}
+	if items := projected.Sources[0].ConfigMap.Items; items != nil {
+		t.Errorf("mountWorkerScripts() first source items = %v, want every snapshot key", items)
+	}
+	want := []corev1.KeyToPath{
+		{Key: "worker-0-test-script.yaml", Path: "worker-1-test-script.yaml"},
+		{Key: "worker-0-test-script.yaml", Path: "worker-2-test-script.yaml"},
+	}
+	if got := projected.Sources[1].ConfigMap.Items; !reflect.DeepEqual(got, want) {
+		t.Errorf("mountWorkerScripts() items = %v, want %v", got, want)
+	}
+}
@@ -14,6 +14,7 @@ package controllers
 
 import (
 	"context"
+	"net/http"
 	"time"
 
 	lt "github.com/artilleryio/artillery-operator/api/v1alpha1"
@@ -23,10 +24,14 @@ import (
 	core "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
 	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/kubernetes"
 	"k8s.io/client-go/tools/record"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/handler"
 	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+	"sigs.k8s.io/controller-runtime/pkg/source"
 )
 
 // LoadTestReconciler reconciles a LoadTest object.
@@ -36,6 +41,30 @@ type LoadTestReconciler struct {
 	Recorder        record.EventRecorder
 	TelemetryConfig telemetry.Config
 	TelemetryClient posthog.Client
+
+	// KubeClient reads worker Pod logs to aggregate test reports, which the controller-runtime client does not support.
+	KubeClient kubernetes.Interface
+
+	// APIReader reads Secrets referenced by worker env vars, and the namespace of deleted LoadTests,
+	// straight from the API server, so that the operator doesn't cache every Secret and Namespace of the cluster.
+	// Defaults to the cached client.
+	APIReader client.Reader
+
+	// WorkerImage is the Artillery image used by workers when a LoadTest does not specify one.
+	WorkerImage string
+
+	// PendingTimeout is the maximum time a worker pod can stay pending before its LoadTest fails.
+	// A zero value disables the timeout.
+	PendingTimeout time.Duration
+	// HTTPClient calls the Pushgateway API to delete published metrics.
+	// Defaults to a client with a 10 seconds timeout.
+	HTTPClient *http.Client
+	// TTLAfterFinished is how long finished LoadTests without spec.ttlSecondsAfterFinished are kept.
+	// A zero value keeps them until deleted.
+	TTLAfterFinished time.Duration
+	// HistoryLimit is the number of finished LoadTests kept per namespace, older ones are deleted.
+	// A zero value disables the limit.
+	HistoryLimit int
 }
 
 /*
@@ -52,6 +81,10 @@ type LoadTestReconciler struct {
 // +kubebuilder:rbac:groups=batch,resources=jobs,verbs=get;list;watch;create;update;patch;delete
 // +kubebuilder:rbac:groups=batch,resources=jobs/status,verbs=get
 // +kubebuilder:rbac:groups=core,resources=pods,verbs=get;list;watch;
+// +kubebuilder:rbac:groups=core,resources=pods/log,verbs=get
+// +kubebuilder:rbac:groups=core,resources=configmaps,verbs=get;list;watch;create;update
+// +kubebuilder:rbac:groups=core,resources=secrets,verbs=get
+// +kubebuilder:rbac:groups=core,resources=namespaces,verbs=get
 // +kubebuilder:rbac:groups="",resources=events,verbs=create;patch
 
 // Reconcile is part of the main kubernetes reconciliation loop which aims to
@@ -60,6 +93,15 @@ type LoadTestReconciler struct {
 // For more details, check Reconcile and its Result here:
 // - https://pkg.go.dev/sigs.k8s.io/controller-runtime@v0.10.0/pkg/reconcile
 func (r *LoadTestReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	result, err := r.reconcile(ctx, req)
+	if err != nil {
+		reconcileErrors.WithLabelValues(req.Namespace, req.Name).Inc()
+	}
+	return result, err
+}
+
+// reconcile reconciles a LoadTest, its errors are counted by Reconcile.
+func (r *LoadTestReconciler) reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
 	logger := log.FromContext(ctx)
 	logger.WithValues("LoadTest.Name", req.Name, "LoadTest.Namespace", req.Namespace)
 	logger.Info("Reconciling LoadTest")
@@ -76,6 +118,7 @@ func (r *LoadTestReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 			// Owned objects are automatically garbage collected. For additional cleanup logic use finalizers.
 			// Return and don't requeue
 			logger.Info("LoadTest resource not found. Ignoring since object must be deleted")
+			reconcileErrors.DeleteLabelValues(req.Namespace, req.Name)
 			return ctrl.Result{}, nil
 		}
 
@@ -84,11 +127,56 @@ func (r *LoadTestReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 		return ctrl.Result{}, err
 	}
 
+	result, err = r.ensureFinalizers(ctx, loadTest, logger)
+	if result != nil {
+		return *result, err
+	}
+
+	result, err = r.ensureMetricsRetention(ctx, loadTest, logger)
+	if result != nil {
+		return *result, err
+	}
+
+	result, err = r.ensureTTL(ctx, loadTest, logger)
+	if result != nil {
+		return *result, err
+	}
+
+	result, err = r.ensureAbort(ctx, loadTest, logger)
+	if result != nil {
+		return *result, err
+	}
+
 	result, err = r.ensureTestScriptConfig(ctx, loadTest, logger)
 	if result != nil {
 		return *result, err
 	}
 
+	result, err = r.ensureExternalConfig(ctx, loadTest, logger)
+	if result != nil {
+		return *result, err
+	}
+
+	result, err = r.ensureEnvConfig(ctx, loadTest, logger)
+	if result != nil {
+		return *result, err
+	}
+
+	result, err = r.ensureEnvironment(ctx, loadTest, logger)
+	if result != nil {
+		return *result, err
+	}
+
+	result, err = r.ensureThresholds(ctx, loadTest, logger)
+	if result != nil {
+		return *result, err
+	}
+
+	result, err = r.ensureSnapshot(ctx, loadTest, logger)
+	if result != nil {
+		return *result, err
+	}
+
 	result, err = r.ensureJob(ctx, loadTest, logger, r.job(loadTest))
 	if result != nil {
 		return *result, err
@@ -106,15 +194,25 @@ func (r *LoadTestReconciler) Reconcile(ctx context.Context, req ctrl.Request) (c
 	}
 
 	// == Finish == == == == ==
-	// Everything went fine, don't requeue
-	return ctrl.Result{}, nil
+	// Everything went fine, only requeue to delete published metrics or the LoadTest once they expire
+	return ctrl.Result{RequeueAfter: r.finishedRequeueAfter(loadTest, time.Now())}, nil
 }
 
 // SetupWithManager sets up the controller with the Manager.
 func (r *LoadTestReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &lt.LoadTest{}, configMapRefsKey, configMapRefs); err != nil {
+		return err
+	}
+
+	if err := metrics.Registry.Register(&loadTestCollector{reader: mgr.GetClient(), now: time.Now}); err != nil {
+		return err
+	}
+
 	return ctrl.NewControllerManagedBy(mgr).
 		For(&lt.LoadTest{}).
 		Owns(&v1.Job{}).
 		Owns(&core.Pod{}).
+		Owns(&core.ConfigMap{}).
+		Watches(&source.Kind{Type: &core.ConfigMap{}}, handler.EnqueueRequestsFromMapFunc(r.loadTestsForConfigMap)).
 		Complete(r)
 }
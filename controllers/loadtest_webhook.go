@@ -0,0 +1,285 @@
+/*
+ * Copyright (c) 2022.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0.
+ *
+ * If a copy of the MPL was not distributed with
+ * this file, You can obtain one at
+ *
+ *   http://mozilla.org/MPL/2.0/
+ */
+
+package controllers
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	lt "github.com/artilleryio/artillery-operator/api/v1alpha1"
+	"github.com/thoas/go-funk"
+	admissionv1 "k8s.io/api/admission/v1"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/equality"
+	"k8s.io/apimachinery/pkg/api/errors"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/apimachinery/pkg/util/validation/field"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/webhook"
+	"sigs.k8s.io/controller-runtime/pkg/webhook/admission"
+)
+
+const (
+	// DefaultCount the number of workers used by a LoadTest that doesn't specify spec.count.
+	DefaultCount = 1
+
+	// MaxCount the maximum number of workers a LoadTest can request.
+	MaxCount = 1000
+
+	mutateLoadTestPath   = "/mutate-loadtest-artillery-io-v1alpha1-loadtest"
+	validateLoadTestPath = "/validate-loadtest-artillery-io-v1alpha1-loadtest"
+)
+
+/*
+	Webhooks are served by the manager's webhook server and configured using kubebuilder markers.
+	Unlike kubebuilder's Defaulter and Validator interfaces, admission handlers can use a client
+	to check the ConfigMaps referenced by a LoadTest.
+
+	For more details, check the webhook documentation:
+	https://book.kubebuilder.io/reference/webhook-overview.html
+*/
+
+// +kubebuilder:webhook:path=/mutate-loadtest-artillery-io-v1alpha1-loadtest,mutating=true,failurePolicy=fail,sideEffects=None,groups=loadtest.artillery.io,resources=loadtests,verbs=create;update,versions=v1alpha1,name=mloadtest.kb.io,admissionReviewVersions=v1
+// +kubebuilder:webhook:path=/validate-loadtest-artillery-io-v1alpha1-loadtest,mutating=false,failurePolicy=fail,sideEffects=None,groups=loadtest.artillery.io,resources=loadtests,verbs=create;update,versions=v1alpha1,name=vloadtest.kb.io,admissionReviewVersions=v1
+
+// SetupWebhookWithManager registers the LoadTest defaulting and validating webhooks with the Manager's webhook server.
+func SetupWebhookWithManager(mgr ctrl.Manager) {
+	server := mgr.GetWebhookServer()
+	server.Register(mutateLoadTestPath, &webhook.Admission{Handler: &LoadTestDefaulter{}})
+	server.Register(validateLoadTestPath, &webhook.Admission{Handler: &LoadTestValidator{Reader: mgr.GetAPIReader()}})
+}
+
+// LoadTestDefaulter sets defaults on created and updated LoadTests.
+type LoadTestDefaulter struct {
+	decoder *admission.Decoder
+}
+
+// InjectDecoder injects the decoder used to decode admission requests.
+func (d *LoadTestDefaulter) InjectDecoder(decoder *admission.Decoder) error {
+	d.decoder = decoder
+	return nil
+}
+
+// Handle defaults the LoadTest's spec.count.
+func (d *LoadTestDefaulter) Handle(_ context.Context, req admission.Request) admission.Response {
+	v := &lt.LoadTest{}
+	if err := d.decoder.Decode(req, v); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	defaultLoadTest(v)
+
+	marshaled, err := json.Marshal(v)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	return admission.PatchResponseFromRaw(req.Object.Raw, marshaled)
+}
+
+// defaultLoadTest sets defaults for unset LoadTest spec fields.
+func defaultLoadTest(v *lt.LoadTest) {
+	if v.Spec.Count == 0 {
+		v.Spec.Count = DefaultCount
+	}
+}
+
+// LoadTestValidator validates created and updated LoadTests.
+type LoadTestValidator struct {
+	// Reader reads referenced ConfigMaps directly from the API server,
+	// as ConfigMaps applied alongside a LoadTest may not be cached yet.
+	Reader  client.Reader
+	decoder *admission.Decoder
+}
+
+// InjectDecoder injects the decoder used to decode admission requests.
+func (h *LoadTestValidator) InjectDecoder(decoder *admission.Decoder) error {
+	h.decoder = decoder
+	return nil
+}
+
+// Handle validates a created LoadTest's spec, and blocks spec updates to a LoadTest once launched,
+// i.e. once its test script snapshot is taken or its workers started, whether pending, running or finished.
+// Updates that leave the spec untouched, e.g. to labels or finalizers, are always allowed,
+// as are updates to spec.suspend and spec.abort used to stop a LoadTest.
+func (h *LoadTestValidator) Handle(ctx context.Context, req admission.Request) admission.Response {
+	v := &lt.LoadTest{}
+	if err := h.decoder.Decode(req, v); err != nil {
+		return admission.Errored(http.StatusBadRequest, err)
+	}
+
+	if req.Operation == admissionv1.Update {
+		old := &lt.LoadTest{}
+		if err := h.decoder.DecodeRaw(req.OldObject, old); err != nil {
+			return admission.Errored(http.StatusBadRequest, err)
+		}
+
+		if !specChanged(old, v) {
+			return admission.Allowed("")
+		}
+		if launched(old) {
+			// Its Job and snapshot are already created, spec updates would be ignored
+			return admission.Denied(field.Forbidden(field.NewPath("spec"),
+				"a launched LoadTest cannot be updated, only spec.suspend and spec.abort can be set").Error())
+		}
+	}
+
+	errs, err := h.validateSpec(ctx, v)
+	if err != nil {
+		return admission.Errored(http.StatusInternalServerError, err)
+	}
+	if len(errs) > 0 {
+		return admission.Denied(errs.ToAggregate().Error())
+	}
+
+	return admission.Allowed("")
+}
+
+// specChanged returns whether a LoadTest's spec was updated, ignoring spec.suspend and spec.abort.
+func specChanged(old *lt.LoadTest, v *lt.LoadTest) bool {
+	oldSpec, spec := old.Spec.DeepCopy(), v.Spec.DeepCopy()
+	oldSpec.Suspend, spec.Suspend = nil, nil
+	oldSpec.Abort, spec.Abort = false, false
+
+	return !equality.Semantic.DeepEqual(oldSpec, spec)
+}
+
+// validateSpec validates the LoadTest's worker count, Pushgateway URL and archive sink, and ensures its test script, set inline
+// or using a ConfigMap, can be parsed and that its referenced ConfigMaps exist.
+// The test script snapshot taken from these ConfigMaps must also fit in a ConfigMap.
+func (h *LoadTestValidator) validateSpec(ctx context.Context, v *lt.LoadTest) (field.ErrorList, error) {
+	var errs field.ErrorList
+
+	specPath := field.NewPath("spec")
+	if v.Spec.Count < 0 || v.Spec.Count > MaxCount {
+		errs = append(errs, field.Invalid(specPath.Child("count"), v.Spec.Count,
+			fmt.Sprintf("must be between 1 and %d", MaxCount)))
+	}
+
+	if publishesPrometheusMetrics(v) {
+		pushgateway := v.Spec.Metrics.Prometheus.Pushgateway
+		if u, err := url.Parse(pushgateway); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			errs = append(errs, field.Invalid(specPath.Child("metrics", "prometheus", "pushgateway"), pushgateway,
+				"must be an http or https URL"))
+		}
+	}
+
+	if archive := v.Spec.Archive; archive != nil {
+		archivePath := specPath.Child("archive")
+		switch {
+		case archive.ConfigMap != nil && archive.ObjectStorage != nil:
+			errs = append(errs, field.Forbidden(archivePath.Child("objectStorage"), "cannot be set along with spec.archive.configMap"))
+		case archive.ConfigMap == nil && archive.ObjectStorage == nil:
+			errs = append(errs, field.Required(archivePath, "one of configMap or objectStorage must be set"))
+		case archive.ObjectStorage != nil:
+			storageURL := archive.ObjectStorage.URL
+			if u, err := url.Parse(storageURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				errs = append(errs, field.Invalid(archivePath.Child("objectStorage", "url"), storageURL,
+					"must be an http or https URL"))
+			}
+		}
+	}
+
+	var sources []*core.ConfigMap
+	testScriptPath := specPath.Child("testScript")
+	configMapPath := testScriptPath.Child("config", "configMap")
+	switch testScript := v.Spec.TestScript; {
+	case testScript.Inline != "" && testScript.Config.ConfigMap != "":
+		errs = append(errs, field.Forbidden(testScriptPath.Child("inline"), "cannot be set along with spec.testScript.config.configMap"))
+	case testScript.Inline != "":
+		if testScript.Config.Key != "" {
+			errs = append(errs, field.Forbidden(testScriptPath.Child("config", "key"), "cannot be set along with spec.testScript.inline"))
+		}
+		errs = append(errs, validateTestScript(v, inlineTestScriptConfigMap(v), testScriptPath.Child("inline"))...)
+		sources = append(sources, inlineTestScriptConfigMap(v))
+	case testScript.Config.ConfigMap == "":
+		errs = append(errs, field.Required(configMapPath, "either spec.testScript.config.configMap or spec.testScript.inline must be set"))
+	default:
+		configMap, err := h.getConfigMap(ctx, v.Namespace, testScript.Config.ConfigMap)
+		switch {
+		case err != nil:
+			return nil, err
+		case configMap == nil:
+			errs = append(errs, field.NotFound(configMapPath, testScript.Config.ConfigMap))
+		default:
+			errs = append(errs, validateTestScript(v, configMap, configMapPath)...)
+			sources = append(sources, configMap)
+		}
+	}
+
+	for _, ref := range externalConfigRefs(v) {
+		cm, err := h.getConfigMap(ctx, v.Namespace, ref.configMap)
+		if err != nil {
+			return nil, err
+		}
+		if cm == nil {
+			errs = append(errs, field.NotFound(field.NewPath(strings.TrimPrefix(ref.field, ".")), ref.configMap))
+		}
+		sources = append(sources, cm)
+	}
+
+	// The snapshot can only be taken once every ConfigMap is found and the test script is valid
+	if len(errs) == 0 {
+		snapshot, err := snapshotConfigMap(v, sources)
+		if err != nil {
+			return nil, err
+		}
+		if msg, ok := snapshotTooLarge(snapshot); ok {
+			errs = append(errs, field.Forbidden(testScriptPath, msg))
+		}
+	}
+
+	return errs, nil
+}
+
+// validateTestScript ensures the test script can be parsed, defines the LoadTest's environment,
+// and has the target, load phases and scenarios required to run a test.
+// Worker test scripts must also be generated from it, e.g. load phases must be numbers to be split across workers.
+func validateTestScript(v *lt.LoadTest, cm *core.ConfigMap, path *field.Path) field.ErrorList {
+	script, err := parseTestScript(cm, testScriptKey(v))
+	if err != nil {
+		return field.ErrorList{field.Invalid(path, cm.Name, fmt.Sprintf("test script could not be parsed: %s", err))}
+	}
+
+	if env := v.Spec.Environment; env != "" && !funk.ContainsString(script.environments(), env) {
+		return field.ErrorList{field.NotFound(field.NewPath("spec", "environment"), env)}
+	}
+
+	var errs field.ErrorList
+	for _, err := range script.validate(v.Spec.Environment) {
+		errs = append(errs, field.Invalid(path, cm.Name, fmt.Sprintf("test script is invalid: %s", err)))
+	}
+	if len(errs) == 0 {
+		if err := validateWorkerScripts(v, cm); err != nil {
+			errs = append(errs, field.Invalid(path, cm.Name, fmt.Sprintf("test script is invalid: %s", err)))
+		}
+	}
+	return errs
+}
+
+// getConfigMap returns the named ConfigMap, or nil if it does not exist.
+func (h *LoadTestValidator) getConfigMap(ctx context.Context, namespace string, name string) (*core.ConfigMap, error) {
+	cm := &core.ConfigMap{}
+	err := h.Reader.Get(ctx, types.NamespacedName{Name: name, Namespace: namespace}, cm)
+	if errors.IsNotFound(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return cm, nil
+}
@@ -0,0 +1,240 @@
+/*
+ * Copyright (c) 2022.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0.
+ *
+ * If a copy of the MPL was not distributed with
+ * this file, You can obtain one at
+ *
+ *   http://mozilla.org/MPL/2.0/
+ */
+
+package controllers
+
+import (
+	"strings"
+
+	lt "github.com/artilleryio/artillery-operator/api/v1alpha1"
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+)
+
+const validTestScript = `config:
+  target: "http://prod-publi-bf4z9b3ymbgs-1669151092.eu-west-1.elb.amazonaws.com:8080"
+  environments:
+    dev:
+      target: "http://localhost:8080"
+  phases:
+    - duration: 60
+      arrivalRate: 1
+scenarios:
+  - flow:
+      - get:
+          url: "/"
+`
+
+func testScriptConfigMap(name string, script string) *core.ConfigMap {
+	return &core.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Data:       map[string]string{TestScriptFilename: script},
+	}
+}
+
+func webhookLoadTest(name string, configMap string) *lt.LoadTest {
+	return &lt.LoadTest{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+		Spec: lt.LoadTestSpec{
+			TestScript: lt.TestScript{
+				Config: lt.Config{ConfigMap: configMap},
+			},
+		},
+	}
+}
+
+var _ = Describe("LoadTest webhook", func() {
+	BeforeEach(func() {
+		for _, cm := range []*core.ConfigMap{
+			testScriptConfigMap("webhook-test-script", validTestScript),
+			testScriptConfigMap("webhook-malformed-script", "config:\n  target: [\n"),
+			testScriptConfigMap("webhook-incomplete-script", "config:\n  target: \"http://localhost:8080\"\n"),
+		} {
+			if err := k8sClient.Create(ctx, cm); err != nil {
+				Expect(errors.IsAlreadyExists(err)).To(BeTrue())
+			}
+		}
+	})
+
+	Context("when creating a LoadTest", func() {
+		It("defaults count", func() {
+			v := webhookLoadTest("webhook-defaults", "webhook-test-script")
+			Expect(k8sClient.Create(ctx, v)).To(Succeed())
+			Expect(v.Spec.Count).To(Equal(DefaultCount))
+		})
+
+		It("rejects negative counts", func() {
+			v := webhookLoadTest("webhook-negative-count", "webhook-test-script")
+			v.Spec.Count = -1
+			Expect(k8sClient.Create(ctx, v)).To(MatchError(ContainSubstring("spec.count")))
+		})
+
+		It("rejects counts above the maximum", func() {
+			v := webhookLoadTest("webhook-absurd-count", "webhook-test-script")
+			v.Spec.Count = MaxCount + 1
+			Expect(k8sClient.Create(ctx, v)).To(MatchError(ContainSubstring("spec.count")))
+		})
+
+		It("rejects a missing test script ConfigMap", func() {
+			v := webhookLoadTest("webhook-missing-script", "does-not-exist")
+			Expect(k8sClient.Create(ctx, v)).To(MatchError(ContainSubstring("spec.testScript.config.configMap")))
+		})
+
+		It("rejects missing external ConfigMaps", func() {
+			v := webhookLoadTest("webhook-missing-payload", "webhook-test-script")
+			v.Spec.TestScript.External = &lt.External{
+				Payload: &lt.Payload{ConfigMaps: []string{"does-not-exist"}},
+			}
+			Expect(k8sClient.Create(ctx, v)).To(MatchError(ContainSubstring("spec.testScript.external.payload.configMaps")))
+		})
+
+		It("rejects a malformed test script", func() {
+			v := webhookLoadTest("webhook-malformed", "webhook-malformed-script")
+			Expect(k8sClient.Create(ctx, v)).To(MatchError(ContainSubstring("test script could not be parsed")))
+		})
+
+		It("rejects a test script missing phases and scenarios", func() {
+			v := webhookLoadTest("webhook-incomplete", "webhook-incomplete-script")
+			Expect(k8sClient.Create(ctx, v)).To(MatchError(And(
+				ContainSubstring("line 2: config.phases is required"),
+				ContainSubstring("line 1: scenarios is required"),
+			)))
+		})
+
+		It("rejects a test script snapshot exceeding the ConfigMap size limit", func() {
+			var payloads []string
+			for _, name := range []string{"webhook-users-csv", "webhook-items-csv"} {
+				cm := &core.ConfigMap{
+					ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "default"},
+					Data:       map[string]string{name + ".csv": strings.Repeat("a", 600*1024)},
+				}
+				if err := k8sClient.Create(ctx, cm); err != nil {
+					Expect(errors.IsAlreadyExists(err)).To(BeTrue())
+				}
+				payloads = append(payloads, name)
+			}
+
+			v := webhookLoadTest("webhook-snapshot-too-large", "webhook-test-script")
+			v.Spec.TestScript.External = &lt.External{Payload: &lt.Payload{ConfigMaps: payloads}}
+			Expect(k8sClient.Create(ctx, v)).To(MatchError(ContainSubstring("exceeding the 1048576 bytes ConfigMap size limit")))
+		})
+
+		It("accepts a test script ConfigMap key", func() {
+			cm := testScriptConfigMap("webhook-keyed-script", "")
+			cm.Data = map[string]string{"smoke.yaml": validTestScript}
+			if err := k8sClient.Create(ctx, cm); err != nil {
+				Expect(errors.IsAlreadyExists(err)).To(BeTrue())
+			}
+
+			v := webhookLoadTest("webhook-keyed", cm.Name)
+			v.Spec.TestScript.Config.Key = "smoke.yaml"
+			Expect(k8sClient.Create(ctx, v)).To(Succeed())
+		})
+
+		It("accepts an inline test script", func() {
+			v := webhookLoadTest("webhook-inline", "")
+			v.Spec.TestScript.Inline = validTestScript
+			Expect(k8sClient.Create(ctx, v)).To(Succeed())
+		})
+
+		It("rejects an invalid inline test script", func() {
+			v := webhookLoadTest("webhook-inline-invalid", "")
+			v.Spec.TestScript.Inline = "config:\n  target: \"http://localhost:8080\"\n"
+			Expect(k8sClient.Create(ctx, v)).To(MatchError(ContainSubstring("spec.testScript.inline")))
+		})
+
+		It("rejects both an inline test script and a ConfigMap", func() {
+			v := webhookLoadTest("webhook-inline-and-configmap", "webhook-test-script")
+			v.Spec.TestScript.Inline = validTestScript
+			Expect(k8sClient.Create(ctx, v)).To(MatchError(ContainSubstring("spec.testScript.inline")))
+		})
+
+		It("rejects a missing test script", func() {
+			v := webhookLoadTest("webhook-no-script", "")
+			Expect(k8sClient.Create(ctx, v)).To(MatchError(ContainSubstring("spec.testScript.config.configMap")))
+		})
+
+		It("rejects an invalid Pushgateway URL", func() {
+			v := webhookLoadTest("webhook-invalid-pushgateway", "webhook-test-script")
+			v.Spec.Metrics = &lt.Metrics{Prometheus: &lt.PrometheusMetrics{Pushgateway: "prometheus-pushgateway:9091"}}
+			Expect(k8sClient.Create(ctx, v)).To(MatchError(ContainSubstring("spec.metrics.prometheus.pushgateway")))
+		})
+
+		It("rejects an archive with both ConfigMap and object storage sinks", func() {
+			v := webhookLoadTest("webhook-archive-both", "webhook-test-script")
+			v.Spec.Archive = &lt.Archive{
+				ConfigMap:     &lt.ArchiveConfigMap{},
+				ObjectStorage: &lt.ArchiveObjectStorage{URL: "http://minio:9000/loadtests"},
+			}
+			Expect(k8sClient.Create(ctx, v)).To(MatchError(ContainSubstring("spec.archive.objectStorage")))
+		})
+
+		It("rejects an invalid archive object storage URL", func() {
+			v := webhookLoadTest("webhook-archive-url", "webhook-test-script")
+			v.Spec.Archive = &lt.Archive{ObjectStorage: &lt.ArchiveObjectStorage{URL: "minio:9000"}}
+			Expect(k8sClient.Create(ctx, v)).To(MatchError(ContainSubstring("spec.archive.objectStorage.url")))
+		})
+
+		It("rejects an environment missing from the test script", func() {
+			v := webhookLoadTest("webhook-unknown-env", "webhook-test-script")
+			v.Spec.Environment = "production"
+			Expect(k8sClient.Create(ctx, v)).To(MatchError(ContainSubstring("spec.environment")))
+		})
+	})
+
+	Context("when updating a launched LoadTest", func() {
+		var v *lt.LoadTest
+
+		BeforeEach(func() {
+			v = webhookLoadTest("webhook-launched", "webhook-test-script")
+			if err := k8sClient.Create(ctx, v); err != nil {
+				Expect(errors.IsAlreadyExists(err)).To(BeTrue())
+			}
+			Expect(k8sClient.Get(ctx, types.NamespacedName{Name: v.Name, Namespace: v.Namespace}, v)).To(Succeed())
+
+			// Workers are still pending
+			v.Status.Phase = lt.LoadTestPhasePending
+			v.Status.TestScript = &lt.LoadTestScriptStatus{
+				Snapshot: &lt.LoadTestSnapshotStatus{ConfigMap: v.Name + "-snapshot", Hash: "sha256:abc"},
+			}
+			Expect(k8sClient.Status().Update(ctx, v)).To(Succeed())
+		})
+
+		It("blocks spec updates", func() {
+			v.Spec.Count = 5
+			Expect(k8sClient.Update(ctx, v)).To(MatchError(ContainSubstring("a launched LoadTest cannot be updated")))
+		})
+
+		It("blocks spec updates once finished", func() {
+			completed := metav1.Now()
+			v.Status.Phase, v.Status.CompletionTime = lt.LoadTestPhaseSucceeded, &completed
+			Expect(k8sClient.Status().Update(ctx, v)).To(Succeed())
+
+			v.Spec.Count = 5
+			Expect(k8sClient.Update(ctx, v)).To(MatchError(ContainSubstring("a launched LoadTest cannot be updated")))
+		})
+
+		It("allows metadata updates", func() {
+			v.Labels = map[string]string{"team": "performance"}
+			Expect(k8sClient.Update(ctx, v)).To(Succeed())
+		})
+
+		It("allows aborting", func() {
+			v.Spec.Abort = true
+			Expect(k8sClient.Update(ctx, v)).To(Succeed())
+		})
+	})
+})
@@ -0,0 +1,381 @@
+/*
+ * Copyright (c) 2022.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0.
+ *
+ * If a copy of the MPL was not distributed with
+ * this file, You can obtain one at
+ *
+ *   http://mozilla.org/MPL/2.0/
+ */
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"time"
+
+	lt "github.com/artilleryio/artillery-operator/api/v1alpha1"
+	"github.com/go-logr/logr"
+	"github.com/robfig/cron/v3"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/runtime"
+	"k8s.io/client-go/tools/record"
+	ref "k8s.io/client-go/tools/reference"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+)
+
+const (
+	// loadTestOwnerKey indexes LoadTests by the name of the LoadTestSchedule that created them.
+	loadTestOwnerKey = ".metadata.controller"
+	// scheduledTimeAnnotation records the time a LoadTest run was scheduled for.
+	scheduledTimeAnnotation = "artillery.io/scheduled-at"
+	// scheduleLabel records the name of the LoadTestSchedule that created a LoadTest run.
+	scheduleLabel = "artillery.io/schedule"
+
+	defaultSuccessfulRunsHistoryLimit int32 = 3
+	defaultFailedRunsHistoryLimit     int32 = 1
+)
+
+// Clock knows how to get the current time.
+// It can be used to fake out timing for testing.
+type Clock interface {
+	Now() time.Time
+}
+
+type realClock struct{}
+
+func (realClock) Now() time.Time { return time.Now() }
+
+// LoadTestScheduleReconciler reconciles a LoadTestSchedule object.
+type LoadTestScheduleReconciler struct {
+	client.Client
+	Scheme   *runtime.Scheme
+	Recorder record.EventRecorder
+	Clock
+}
+
+// +kubebuilder:rbac:groups=loadtest.artillery.io,resources=loadtestschedules,verbs=get;list;watch;create;update;patch;delete
+// +kubebuilder:rbac:groups=loadtest.artillery.io,resources=loadtestschedules/status,verbs=get;update;patch
+// +kubebuilder:rbac:groups=loadtest.artillery.io,resources=loadtestschedules/finalizers,verbs=update
+
+// Reconcile creates LoadTest runs from a LoadTestSchedule's template on its schedule,
+// tracks active runs and prunes old runs beyond the configured history limits.
+//
+// For more details, check the CronJob tutorial this controller follows:
+// https://book.kubebuilder.io/cronjob-tutorial/controller-implementation.html
+func (r *LoadTestScheduleReconciler) Reconcile(ctx context.Context, req ctrl.Request) (ctrl.Result, error) {
+	logger := log.FromContext(ctx).WithValues("LoadTestSchedule.Name", req.Name, "LoadTestSchedule.Namespace", req.Namespace)
+	logger.Info("Reconciling LoadTestSchedule")
+
+	schedule := &lt.LoadTestSchedule{}
+	if err := r.Get(ctx, req.NamespacedName, schedule); err != nil {
+		if errors.IsNotFound(err) {
+			// Created LoadTest runs are automatically garbage collected.
+			logger.Info("LoadTestSchedule resource not found. Ignoring since object must be deleted")
+			return ctrl.Result{}, nil
+		}
+
+		logger.Error(err, "Failed to get LoadTestSchedule")
+		return ctrl.Result{}, err
+	}
+
+	var runs lt.LoadTestList
+	if err := r.List(ctx, &runs, client.InNamespace(req.Namespace), client.MatchingFields{loadTestOwnerKey: req.Name}); err != nil {
+		logger.Error(err, "Failed to list LoadTest runs")
+		return ctrl.Result{}, err
+	}
+
+	active, successful, failed := classifyRuns(runs.Items)
+
+	if err := r.updateScheduleStatus(ctx, schedule, runs.Items, active, successful, logger); err != nil {
+		return ctrl.Result{}, err
+	}
+
+	r.pruneRuns(ctx, successful, historyLimit(schedule.Spec.SuccessfulRunsHistoryLimit, defaultSuccessfulRunsHistoryLimit), logger)
+	r.pruneRuns(ctx, failed, historyLimit(schedule.Spec.FailedRunsHistoryLimit, defaultFailedRunsHistoryLimit), logger)
+
+	if schedule.Spec.Suspend != nil && *schedule.Spec.Suspend {
+		logger.Info("LoadTestSchedule suspended, skipping")
+		return ctrl.Result{}, nil
+	}
+
+	now := r.Now()
+	missedRun, nextRun, err := nextSchedule(schedule, now)
+	if err != nil {
+		// The schedule won't be valid until it's updated, so don't requeue.
+		logger.Error(err, "Unable to figure out LoadTestSchedule schedule")
+		r.Recorder.Event(schedule, "Warning", "InvalidSchedule", err.Error())
+		return ctrl.Result{}, nil
+	}
+
+	scheduledResult := ctrl.Result{RequeueAfter: nextRun.Sub(now)}
+	logger = logger.WithValues("now", now, "next run", nextRun)
+
+	if missedRun.IsZero() {
+		logger.Info("No upcoming scheduled times, sleeping until next")
+		return scheduledResult, nil
+	}
+
+	// Runs missed beyond the starting deadline were already ignored by nextSchedule
+	logger = logger.WithValues("current run", missedRun)
+
+	switch {
+	case schedule.Spec.ConcurrencyPolicy == lt.ForbidConcurrent && len(active) > 0:
+		if err := r.skipRun(ctx, schedule, missedRun, len(active), logger); err != nil {
+			return ctrl.Result{}, err
+		}
+		return scheduledResult, nil
+
+	case schedule.Spec.ConcurrencyPolicy == lt.ReplaceConcurrent:
+		for i := range active {
+			if err := r.abortRun(ctx, &active[i]); err != nil {
+				logger.Error(err, "Unable to abort active LoadTest run", "LoadTest.Name", active[i].Name)
+				return ctrl.Result{}, err
+			}
+			r.Recorder.Eventf(schedule, "Normal", "ReplacedRun", "Aborted Load Test run %s to replace it with a new run", active[i].Name)
+		}
+	}
+
+	run, err := r.loadTestForSchedule(schedule, missedRun)
+	if err != nil {
+		logger.Error(err, "Unable to construct LoadTest run from template")
+		return scheduledResult, nil
+	}
+
+	if err := r.Create(ctx, run); err != nil {
+		if errors.IsAlreadyExists(err) {
+			// The run was created in a previous reconcile
+			return scheduledResult, nil
+		}
+		logger.Error(err, "Unable to create LoadTest run", "LoadTest.Name", run.Name)
+		return ctrl.Result{}, err
+	}
+
+	logger.Info("Created LoadTest run", "LoadTest.Name", run.Name)
+	r.Recorder.Eventf(schedule, "Normal", "Created", "Created Load Test run: %s", run.Name)
+
+	return scheduledResult, nil
+}
+
+// skipRun skips a run blocked by the Forbid concurrency policy.
+// The skipped run is recorded as the last scheduled run, so that it's skipped, and published as an event, only once.
+func (r *LoadTestScheduleReconciler) skipRun(
+	ctx context.Context,
+	schedule *lt.LoadTestSchedule,
+	scheduled time.Time,
+	active int,
+	logger logr.Logger,
+) error {
+	logger.Info("Concurrency policy blocks concurrent runs, skipping", "active runs", active)
+
+	schedule.Status.LastScheduleTime = &metav1.Time{Time: scheduled}
+	if err := r.Status().Update(ctx, schedule); err != nil {
+		logger.Error(err, "Unable to update LoadTestSchedule status")
+		return err
+	}
+
+	r.Recorder.Eventf(schedule, "Normal", "SkippedRun", "Skipped Load Test run scheduled at %s, %d run(s) still active", scheduled.Format(time.RFC3339), active)
+	return nil
+}
+
+// classifyRuns splits LoadTest runs into active, successful and failed runs.
+// Aborted runs, along with runs rejected before their workers started, count as failed.
+func classifyRuns(runs []lt.LoadTest) (active, successful, failed []lt.LoadTest) {
+	for _, run := range runs {
+		switch run.Status.Phase {
+		case lt.LoadTestPhaseSucceeded:
+			successful = append(successful, run)
+		case lt.LoadTestPhaseFailed, lt.LoadTestPhaseAborted:
+			failed = append(failed, run)
+		default:
+			active = append(active, run)
+		}
+	}
+	return active, successful, failed
+}
+
+// updateScheduleStatus records the LoadTestSchedule's active runs, along with its last scheduled and successful runs.
+func (r *LoadTestScheduleReconciler) updateScheduleStatus(
+	ctx context.Context,
+	schedule *lt.LoadTestSchedule,
+	runs []lt.LoadTest,
+	active []lt.LoadTest,
+	successful []lt.LoadTest,
+	logger logr.Logger,
+) error {
+	schedule.Status.Active = nil
+	for i := range active {
+		runRef, err := ref.GetReference(r.Scheme, &active[i])
+		if err != nil {
+			logger.Error(err, "Unable to make reference to active LoadTest run", "LoadTest.Name", active[i].Name)
+			continue
+		}
+		schedule.Status.Active = append(schedule.Status.Active, *runRef)
+	}
+
+	for _, run := range runs {
+		if scheduled := scheduledTime(run); !scheduled.IsZero() {
+			if schedule.Status.LastScheduleTime == nil || schedule.Status.LastScheduleTime.Time.Before(scheduled) {
+				schedule.Status.LastScheduleTime = &metav1.Time{Time: scheduled}
+			}
+		}
+	}
+
+	for _, run := range successful {
+		if c := run.Status.CompletionTime; c != nil {
+			if schedule.Status.LastSuccessfulTime == nil || schedule.Status.LastSuccessfulTime.Before(c) {
+				schedule.Status.LastSuccessfulTime = c.DeepCopy()
+			}
+		}
+	}
+
+	if err := r.Status().Update(ctx, schedule); err != nil {
+		logger.Error(err, "Unable to update LoadTestSchedule status")
+		return err
+	}
+	return nil
+}
+
+// pruneRuns deletes the oldest LoadTest runs beyond the provided history limit.
+// Deletion failures are logged and retried on the next reconcile.
+func (r *LoadTestScheduleReconciler) pruneRuns(ctx context.Context, runs []lt.LoadTest, limit int32, logger logr.Logger) {
+	if int32(len(runs)) <= limit {
+		return
+	}
+
+	sort.Slice(runs, func(i, j int) bool {
+		return scheduledTime(runs[i]).Before(scheduledTime(runs[j]))
+	})
+
+	for i := 0; i < len(runs)-int(limit); i++ {
+		if err := r.Delete(ctx, &runs[i], client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			logger.Error(err, "Unable to delete old LoadTest run", "LoadTest.Name", runs[i].Name)
+			continue
+		}
+		logger.Info("Deleted old LoadTest run", "LoadTest.Name", runs[i].Name)
+	}
+}
+
+// abortRun aborts an active LoadTest run using its spec.abort, keeping the run and its status.
+func (r *LoadTestScheduleReconciler) abortRun(ctx context.Context, run *lt.LoadTest) error {
+	patch := client.MergeFrom(run.DeepCopy())
+	run.Spec.Abort = true
+	return client.IgnoreNotFound(r.Patch(ctx, run, patch))
+}
+
+// loadTestForSchedule creates a LoadTest run from the LoadTestSchedule's template for the provided scheduled time.
+func (r *LoadTestScheduleReconciler) loadTestForSchedule(schedule *lt.LoadTestSchedule, scheduled time.Time) (*lt.LoadTest, error) {
+	template := schedule.Spec.LoadTestTemplate
+
+	run := &lt.LoadTest{
+		ObjectMeta: metav1.ObjectMeta{
+			// Runs are named after their schedule time, so a run is only created once per scheduled time.
+			Name:        fmt.Sprintf("%s-%d", schedule.Name, scheduled.Unix()),
+			Namespace:   schedule.Namespace,
+			Labels:      mergeMaps(template.Labels, map[string]string{scheduleLabel: schedule.Name}),
+			Annotations: mergeMaps(template.Annotations, map[string]string{scheduledTimeAnnotation: scheduled.Format(time.RFC3339)}),
+		},
+		Spec: *template.Spec.DeepCopy(),
+	}
+
+	if err := ctrl.SetControllerReference(schedule, run, r.Scheme); err != nil {
+		return nil, err
+	}
+	return run, nil
+}
+
+// scheduledTime returns the time a LoadTest run was scheduled for, or a zero time if it's unknown.
+func scheduledTime(run lt.LoadTest) time.Time {
+	scheduled, err := time.Parse(time.RFC3339, run.Annotations[scheduledTimeAnnotation])
+	if err != nil {
+		return time.Time{}
+	}
+	return scheduled
+}
+
+func historyLimit(limit *int32, defaultLimit int32) int32 {
+	if limit != nil {
+		return *limit
+	}
+	return defaultLimit
+}
+
+// nextSchedule returns the latest scheduled time that was missed, if any, along with the next scheduled time.
+// Scheduled times are interpreted in the LoadTestSchedule's time zone.
+func nextSchedule(schedule *lt.LoadTestSchedule, now time.Time) (lastMissed time.Time, next time.Time, err error) {
+	sched, err := cron.ParseStandard(schedule.Spec.Schedule)
+	if err != nil {
+		return time.Time{}, time.Time{}, fmt.Errorf("unparseable schedule %q: %v", schedule.Spec.Schedule, err)
+	}
+
+	location := time.Local
+	if tz := schedule.Spec.TimeZone; tz != nil && *tz != "" {
+		location, err = time.LoadLocation(*tz)
+		if err != nil {
+			return time.Time{}, time.Time{}, fmt.Errorf("unknown time zone %q: %v", *tz, err)
+		}
+	}
+	now = now.In(location)
+
+	// Start counting from the last run, or from the schedule's creation if it never ran.
+	var earliest time.Time
+	if schedule.Status.LastScheduleTime != nil {
+		earliest = schedule.Status.LastScheduleTime.Time
+	} else {
+		earliest = schedule.CreationTimestamp.Time
+	}
+	if d := schedule.Spec.StartingDeadlineSeconds; d != nil {
+		// Runs missed beyond the starting deadline can be ignored
+		if deadline := now.Add(-time.Duration(*d) * time.Second); deadline.After(earliest) {
+			earliest = deadline
+		}
+	}
+	earliest = earliest.In(location)
+
+	if earliest.After(now) {
+		return time.Time{}, sched.Next(now), nil
+	}
+
+	starts := 0
+	for t := sched.Next(earliest); !t.After(now); t = sched.Next(t) {
+		lastMissed = t
+		// An excessive number of missed runs usually means the clock was skewed,
+		// so bail out rather than spinning through every missed run.
+		starts++
+		if starts > 100 {
+			return time.Time{}, time.Time{}, fmt.Errorf("too many missed start times (> 100), set or decrease .spec.startingDeadlineSeconds or check clock skew")
+		}
+	}
+
+	return lastMissed, sched.Next(now), nil
+}
+
+// SetupWithManager sets up the controller with the Manager.
+// LoadTest runs are indexed by their owning LoadTestSchedule, so they can be listed efficiently.
+func (r *LoadTestScheduleReconciler) SetupWithManager(mgr ctrl.Manager) error {
+	if r.Clock == nil {
+		r.Clock = realClock{}
+	}
+
+	if err := mgr.GetFieldIndexer().IndexField(context.Background(), &lt.LoadTest{}, loadTestOwnerKey, func(obj client.Object) []string {
+		owner := metav1.GetControllerOf(obj)
+		if owner == nil || owner.APIVersion != lt.GroupVersion.String() || owner.Kind != "LoadTestSchedule" {
+			return nil
+		}
+		return []string{owner.Name}
+	}); err != nil {
+		return err
+	}
+
+	return ctrl.NewControllerManagedBy(mgr).
+		For(&lt.LoadTestSchedule{}).
+		Owns(&lt.LoadTest{}).
+		Complete(r)
+}
@@ -0,0 +1,178 @@
+/*
+ * Copyright (c) 2022.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0.
+ *
+ * If a copy of the MPL was not distributed with
+ * this file, You can obtain one at
+ *
+ *   http://mozilla.org/MPL/2.0/
+ */
+
+package controllers
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	lt "github.com/artilleryio/artillery-operator/api/v1alpha1"
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestNextSchedule(t *testing.T) {
+	created := time.Date(2022, 3, 1, 0, 30, 0, 0, time.UTC)
+	london := "Europe/London"
+	unknown := "Mars/Olympus_Mons"
+	deadline := int64(600)
+
+	tests := []struct {
+		name           string
+		spec           lt.LoadTestScheduleSpec
+		lastSchedule   *time.Time
+		now            time.Time
+		wantLastMissed time.Time
+		wantNext       time.Time
+		wantErr        bool
+	}{
+		{
+			name:     "no missed runs",
+			spec:     lt.LoadTestScheduleSpec{Schedule: "0 2 * * *"},
+			now:      time.Date(2022, 3, 1, 1, 0, 0, 0, time.UTC),
+			wantNext: time.Date(2022, 3, 1, 2, 0, 0, 0, time.UTC),
+		},
+		{
+			name:           "latest missed run",
+			spec:           lt.LoadTestScheduleSpec{Schedule: "@hourly"},
+			now:            time.Date(2022, 3, 1, 3, 10, 0, 0, time.UTC),
+			wantLastMissed: time.Date(2022, 3, 1, 3, 0, 0, 0, time.UTC),
+			wantNext:       time.Date(2022, 3, 1, 4, 0, 0, 0, time.UTC),
+		},
+		{
+			name:         "counts from the last scheduled run",
+			spec:         lt.LoadTestScheduleSpec{Schedule: "@hourly"},
+			lastSchedule: timePtr(time.Date(2022, 3, 1, 3, 0, 0, 0, time.UTC)),
+			now:          time.Date(2022, 3, 1, 3, 10, 0, 0, time.UTC),
+			wantNext:     time.Date(2022, 3, 1, 4, 0, 0, 0, time.UTC),
+		},
+		{
+			name: "time zone",
+			// British Summer Time, 2am in London is 1am UTC
+			spec:           lt.LoadTestScheduleSpec{Schedule: "0 2 * * *", TimeZone: &london},
+			lastSchedule:   timePtr(time.Date(2022, 6, 1, 1, 0, 0, 0, time.UTC)),
+			now:            time.Date(2022, 6, 2, 1, 30, 0, 0, time.UTC),
+			wantLastMissed: time.Date(2022, 6, 2, 1, 0, 0, 0, time.UTC),
+			wantNext:       time.Date(2022, 6, 3, 1, 0, 0, 0, time.UTC),
+		},
+		{
+			name:     "runs missed beyond the starting deadline are ignored",
+			spec:     lt.LoadTestScheduleSpec{Schedule: "@hourly", StartingDeadlineSeconds: &deadline},
+			now:      time.Date(2022, 3, 1, 3, 30, 0, 0, time.UTC),
+			wantNext: time.Date(2022, 3, 1, 4, 0, 0, 0, time.UTC),
+		},
+		{
+			name:    "too many missed runs",
+			spec:    lt.LoadTestScheduleSpec{Schedule: "* * * * *"},
+			now:     created.Add(3 * time.Hour),
+			wantErr: true,
+		},
+		{
+			name:    "invalid schedule",
+			spec:    lt.LoadTestScheduleSpec{Schedule: "every night"},
+			now:     created,
+			wantErr: true,
+		},
+		{
+			name:    "unknown time zone",
+			spec:    lt.LoadTestScheduleSpec{Schedule: "@daily", TimeZone: &unknown},
+			now:     created,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			schedule := &lt.LoadTestSchedule{
+				ObjectMeta: metav1.ObjectMeta{CreationTimestamp: metav1.NewTime(created)},
+				Spec:       tt.spec,
+			}
+			if tt.lastSchedule != nil {
+				schedule.Status.LastScheduleTime = &metav1.Time{Time: *tt.lastSchedule}
+			}
+
+			lastMissed, next, err := nextSchedule(schedule, tt.now)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("nextSchedule() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+			if !lastMissed.Equal(tt.wantLastMissed) {
+				t.Errorf("nextSchedule() lastMissed = %v, want %v", lastMissed, tt.wantLastMissed)
+			}
+			if !next.Equal(tt.wantNext) {
+				t.Errorf("nextSchedule() next = %v, want %v", next, tt.wantNext)
+			}
+		})
+	}
+}
+
+func TestClassifyRuns(t *testing.T) {
+	runs := []lt.LoadTest{
+		{Status: lt.LoadTestStatus{Phase: lt.LoadTestPhaseSucceeded}},
+		{Status: lt.LoadTestStatus{Phase: lt.LoadTestPhaseFailed}},
+		{Status: lt.LoadTestStatus{Phase: lt.LoadTestPhaseAborted}},
+		{Status: lt.LoadTestStatus{Phase: lt.LoadTestPhaseRunning}},
+		{Status: lt.LoadTestStatus{Phase: lt.LoadTestPhasePending}},
+		{},
+	}
+
+	active, successful, failed := classifyRuns(runs)
+	if len(active) != 3 || len(successful) != 1 || len(failed) != 2 {
+		t.Errorf("classifyRuns() = %d active, %d successful, %d failed, want 3, 1, 2", len(active), len(successful), len(failed))
+	}
+}
+
+func timePtr(t time.Time) *time.Time {
+	return &t
+}
+
+func TestSkipRun(t *testing.T) {
+	created := time.Date(2022, 3, 1, 0, 30, 0, 0, time.UTC)
+	now := time.Date(2022, 3, 1, 3, 10, 0, 0, time.UTC)
+	schedule := &lt.LoadTestSchedule{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "default", CreationTimestamp: metav1.NewTime(created)},
+		Spec:       lt.LoadTestScheduleSpec{Schedule: "@hourly", ConcurrencyPolicy: lt.ForbidConcurrent},
+	}
+
+	recorder := record.NewFakeRecorder(10)
+	r := &LoadTestScheduleReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(testScheme()).WithObjects(schedule).Build(),
+		Recorder: recorder,
+	}
+
+	missed, _, err := nextSchedule(schedule, now)
+	if err != nil || missed.IsZero() {
+		t.Fatalf("nextSchedule() = %v, %v, want a missed run", missed, err)
+	}
+	if err := r.skipRun(context.Background(), schedule, missed, 1, logr.Discard()); err != nil {
+		t.Fatalf("skipRun() error = %v", err)
+	}
+	if len(recorder.Events) != 1 {
+		t.Errorf("skipRun() published %d events, want 1", len(recorder.Events))
+	}
+
+	// Reconciling again, e.g. once the active run's status is updated, doesn't skip the same run again
+	found := &lt.LoadTestSchedule{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: schedule.Name, Namespace: schedule.Namespace}, found); err != nil {
+		t.Fatal(err)
+	}
+	if missed, _, err := nextSchedule(found, now); err != nil || !missed.IsZero() {
+		t.Errorf("nextSchedule() after skipping = %v, %v, want no missed run", missed, err)
+	}
+}
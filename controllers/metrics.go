@@ -0,0 +1,117 @@
+/*
+ * Copyright (c) 2022.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0.
+ *
+ * If a copy of the MPL was not distributed with
+ * this file, You can obtain one at
+ *
+ *   http://mozilla.org/MPL/2.0/
+ */
+
+package controllers
+
+import (
+	"context"
+	"time"
+
+	lt "github.com/artilleryio/artillery-operator/api/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/metrics"
+)
+
+/*
+	Operator metrics are registered on the controller-runtime registry,
+	and served by the manager's metrics endpoint alongside controller-runtime's own metrics.
+
+	For more details, check the kubebuilder metrics documentation:
+	https://book.kubebuilder.io/reference/metrics.html
+*/
+
+var (
+	loadTestsDesc = prometheus.NewDesc(
+		"artillery_loadtests",
+		"The number of LoadTests by phase.",
+		[]string{"namespace", "phase"}, nil,
+	)
+	loadTestWorkersDesc = prometheus.NewDesc(
+		"artillery_loadtest_workers",
+		"The number of LoadTest workers by state, one of active, succeeded or failed.",
+		[]string{"namespace", "loadtest", "state"}, nil,
+	)
+	loadTestDurationDesc = prometheus.NewDesc(
+		"artillery_loadtest_duration_seconds",
+		"The time LoadTest workers have been running for, or ran for once finished.",
+		[]string{"namespace", "loadtest"}, nil,
+	)
+
+	// reconcileErrors counts failed LoadTest reconciliations.
+	reconcileErrors = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Name: "artillery_loadtest_reconcile_errors_total",
+		Help: "The number of failed LoadTest reconciliations.",
+	}, []string{"namespace", "loadtest"})
+)
+
+func init() {
+	metrics.Registry.MustRegister(reconcileErrors)
+}
+
+// loadTestCollector collects LoadTest metrics from the manager's cache when metrics are scraped,
+// so deleted LoadTests are no longer reported.
+type loadTestCollector struct {
+	reader client.Reader
+	now    func() time.Time
+}
+
+// Describe sends the descriptors of collected LoadTest metrics.
+func (c *loadTestCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- loadTestsDesc
+	ch <- loadTestWorkersDesc
+	ch <- loadTestDurationDesc
+}
+
+// Collect sends LoadTest metrics for every LoadTest in the cluster.
+func (c *loadTestCollector) Collect(ch chan<- prometheus.Metric) {
+	var loadTests lt.LoadTestList
+	if err := c.reader.List(context.Background(), &loadTests); err != nil {
+		log.Log.Error(err, "Failed to list LoadTests to collect metrics")
+		return
+	}
+
+	type phaseKey struct {
+		namespace string
+		phase     lt.LoadTestPhase
+	}
+	phases := map[phaseKey]int{}
+
+	for _, v := range loadTests.Items {
+		phase := v.Status.Phase
+		if phase == "" {
+			phase = lt.LoadTestPhasePending
+		}
+		phases[phaseKey{v.Namespace, phase}]++
+
+		for state, count := range map[string]int32{
+			"active":    v.Status.Active,
+			"succeeded": v.Status.Succeeded,
+			"failed":    v.Status.Failed,
+		} {
+			ch <- prometheus.MustNewConstMetric(loadTestWorkersDesc, prometheus.GaugeValue, float64(count), v.Namespace, v.Name, state)
+		}
+
+		if start := v.Status.StartTime; start != nil {
+			end := c.now()
+			if v.Status.CompletionTime != nil {
+				end = v.Status.CompletionTime.Time
+			}
+			ch <- prometheus.MustNewConstMetric(loadTestDurationDesc, prometheus.GaugeValue, end.Sub(start.Time).Seconds(), v.Namespace, v.Name)
+		}
+	}
+
+	for k, count := range phases {
+		ch <- prometheus.MustNewConstMetric(loadTestsDesc, prometheus.GaugeValue, float64(count), k.namespace, string(k.phase))
+	}
+}
@@ -0,0 +1,81 @@
+/*
+ * Copyright (c) 2022.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0.
+ *
+ * If a copy of the MPL was not distributed with
+ * this file, You can obtain one at
+ *
+ *   http://mozilla.org/MPL/2.0/
+ */
+
+package controllers
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	lt "github.com/artilleryio/artillery-operator/api/v1alpha1"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func TestLoadTestCollector(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	started := metav1.NewTime(now.Add(-90 * time.Second))
+	completed := metav1.NewTime(now.Add(-30 * time.Second))
+
+	running := &lt.LoadTest{
+		ObjectMeta: metav1.ObjectMeta{Name: "running", Namespace: "perf"},
+		Status: lt.LoadTestStatus{
+			Phase:     lt.LoadTestPhaseRunning,
+			StartTime: &started,
+			Active:    2,
+			Succeeded: 1,
+		},
+	}
+	succeeded := &lt.LoadTest{
+		ObjectMeta: metav1.ObjectMeta{Name: "succeeded", Namespace: "perf"},
+		Status: lt.LoadTestStatus{
+			Phase:          lt.LoadTestPhaseSucceeded,
+			StartTime:      &started,
+			CompletionTime: &completed,
+			Succeeded:      3,
+		},
+	}
+	pending := &lt.LoadTest{ObjectMeta: metav1.ObjectMeta{Name: "pending", Namespace: "perf"}}
+
+	c := &loadTestCollector{
+		reader: fake.NewClientBuilder().WithScheme(testScheme()).WithObjects(running, succeeded, pending).Build(),
+		now:    func() time.Time { return now },
+	}
+
+	want := `
+# HELP artillery_loadtest_duration_seconds The time LoadTest workers have been running for, or ran for once finished.
+# TYPE artillery_loadtest_duration_seconds gauge
+artillery_loadtest_duration_seconds{loadtest="running",namespace="perf"} 90
+artillery_loadtest_duration_seconds{loadtest="succeeded",namespace="perf"} 60
+# HELP artillery_loadtest_workers The number of LoadTest workers by state, one of active, succeeded or failed.
+# TYPE artillery_loadtest_workers gauge
+artillery_loadtest_workers{loadtest="pending",namespace="perf",state="active"} 0
+artillery_loadtest_workers{loadtest="pending",namespace="perf",state="failed"} 0
+artillery_loadtest_workers{loadtest="pending",namespace="perf",state="succeeded"} 0
+artillery_loadtest_workers{loadtest="running",namespace="perf",state="active"} 2
+artillery_loadtest_workers{loadtest="running",namespace="perf",state="failed"} 0
+artillery_loadtest_workers{loadtest="running",namespace="perf",state="succeeded"} 1
+artillery_loadtest_workers{loadtest="succeeded",namespace="perf",state="active"} 0
+artillery_loadtest_workers{loadtest="succeeded",namespace="perf",state="failed"} 0
+artillery_loadtest_workers{loadtest="succeeded",namespace="perf",state="succeeded"} 3
+# HELP artillery_loadtests The number of LoadTests by phase.
+# TYPE artillery_loadtests gauge
+artillery_loadtests{namespace="perf",phase="Pending"} 1
+artillery_loadtests{namespace="perf",phase="Running"} 1
+artillery_loadtests{namespace="perf",phase="Succeeded"} 1
+`
+	if err := testutil.CollectAndCompare(c, strings.NewReader(want)); err != nil {
+		t.Error(err)
+	}
+}
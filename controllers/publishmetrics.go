@@ -0,0 +1,98 @@
+/*
+ * Copyright (c) 2022.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0.
+ *
+ * If a copy of the MPL was not distributed with
+ * this file, You can obtain one at
+ *
+ *   http://mozilla.org/MPL/2.0/
+ */
+
+package controllers
+
+import (
+	lt "github.com/artilleryio/artillery-operator/api/v1alpha1"
+	"gopkg.in/yaml.v3"
+)
+
+// DefaultMetricsPrefix the prefix of metric names published to Prometheus when spec.metrics.prometheus.prefix is not set.
+const DefaultMetricsPrefix = "artillery_k8s"
+
+// publishesPrometheusMetrics returns whether the LoadTest's workers publish test report metrics to Prometheus.
+func publishesPrometheusMetrics(v *lt.LoadTest) bool {
+	return v.Spec.Metrics != nil && v.Spec.Metrics.Prometheus != nil
+}
+
+// publishMetricsTags returns the tags added to metrics published by every worker.
+// The worker's ID is read from its WORKER_ID env var, so that every worker runs the same test script.
+func publishMetricsTags(v *lt.LoadTest) []string {
+	tags := []string{
+		"load_test_id:" + v.Name,
+		"namespace:" + v.Namespace,
+		"worker_id:{{ $processEnvironment.WORKER_ID }}",
+	}
+	return append(tags, v.Spec.Metrics.Prometheus.Tags...)
+}
+
+// addPublishMetrics adds a prometheus publish-metrics plugin entry to the test script's config.plugins,
+// configured using the LoadTest's spec.metrics.prometheus.
+// Any publish-metrics entries already defined by the test script are kept.
+func addPublishMetrics(root *yaml.Node, v *lt.LoadTest) error {
+	prometheus := v.Spec.Metrics.Prometheus
+	prefix := prometheus.Prefix
+	if prefix == "" {
+		prefix = DefaultMetricsPrefix
+	}
+
+	entry := &yaml.Node{}
+	if err := entry.Encode(map[string]interface{}{
+		"type":        "prometheus",
+		"pushgateway": prometheus.Pushgateway,
+		"prefix":      prefix,
+		"tags":        publishMetricsTags(v),
+	}); err != nil {
+		return err
+	}
+
+	config, err := mappingChild(root, "config")
+	if err != nil {
+		return err
+	}
+	plugins, err := mappingChild(config, "plugins")
+	if err != nil {
+		return err
+	}
+
+	publishMetrics := mappingValue(plugins, "publish-metrics")
+	switch {
+	case publishMetrics == nil:
+		publishMetrics = &yaml.Node{Kind: yaml.SequenceNode, Tag: "!!seq"}
+		plugins.Content = append(plugins.Content, scalarNode("publish-metrics"), publishMetrics)
+	case publishMetrics.Kind != yaml.SequenceNode:
+		return scriptError{line: publishMetrics.Line, message: "config.plugins.publish-metrics must be a list"}
+	}
+
+	publishMetrics.Content = append(publishMetrics.Content, entry)
+	return nil
+}
+
+// mappingChild returns the mapping held by the provided key in a YAML mapping, adding it if it's not found.
+func mappingChild(n *yaml.Node, key string) (*yaml.Node, error) {
+	if child := mappingValue(n, key); child != nil {
+		if child.Kind != yaml.MappingNode {
+			return nil, scriptError{line: child.Line, message: key + " must be a mapping"}
+		}
+		return child, nil
+	}
+
+	child := &yaml.Node{Kind: yaml.MappingNode, Tag: "!!map"}
+	n.Content = append(n.Content, scalarNode(key), child)
+	return child, nil
+}
+
+// scalarNode creates a YAML string node.
+func scalarNode(value string) *yaml.Node {
+	return &yaml.Node{Kind: yaml.ScalarNode, Tag: "!!str", Value: value}
+}
@@ -0,0 +1,136 @@
+/*
+ * Copyright (c) 2022.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0.
+ *
+ * If a copy of the MPL was not distributed with
+ * this file, You can obtain one at
+ *
+ *   http://mozilla.org/MPL/2.0/
+ */
+
+package controllers
+
+import (
+	"reflect"
+	"testing"
+
+	lt "github.com/artilleryio/artillery-operator/api/v1alpha1"
+	"gopkg.in/yaml.v3"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestWorkerScriptsPublishMetrics(t *testing.T) {
+	type publishMetrics struct {
+		Type        string   `yaml:"type"`
+		Pushgateway string   `yaml:"pushgateway"`
+		Prefix      string   `yaml:"prefix"`
+		Tags        []string `yaml:"tags"`
+	}
+	type script struct {
+		Config struct {
+			Plugins struct {
+				PublishMetrics []publishMetrics `yaml:"publish-metrics"`
+			} `yaml:"plugins"`
+		} `yaml:"config"`
+	}
+
+	statsd := `config:
+  target: "http://localhost:8080"
+  plugins:
+    publish-metrics:
+      - type: statsd
+  phases:
+    - duration: 60
+      arrivalRate: 1
+scenarios:
+  - flow: []
+`
+
+	tests := []struct {
+		name       string
+		script     string
+		prometheus lt.PrometheusMetrics
+		want       []publishMetrics
+	}{
+		{
+			name:       "adds the plugin",
+			script:     validTestScript,
+			prometheus: lt.PrometheusMetrics{Pushgateway: "http://prometheus-pushgateway:9091"},
+			want: []publishMetrics{{
+				Type:        "prometheus",
+				Pushgateway: "http://prometheus-pushgateway:9091",
+				Prefix:      "artillery_k8s",
+				Tags:        []string{"load_test_id:nightly", "namespace:perf", "worker_id:{{ $processEnvironment.WORKER_ID }}"},
+			}},
+		},
+		{
+			name:   "keeps existing publish-metrics entries",
+			script: statsd,
+			prometheus: lt.PrometheusMetrics{
+				Pushgateway: "http://pushgateway:9091",
+				Prefix:      "checkout",
+				Tags:        []string{"team:checkout"},
+			},
+			want: []publishMetrics{
+				{Type: "statsd"},
+				{
+					Type:        "prometheus",
+					Pushgateway: "http://pushgateway:9091",
+					Prefix:      "checkout",
+					Tags:        []string{"load_test_id:nightly", "namespace:perf", "worker_id:{{ $processEnvironment.WORKER_ID }}", "team:checkout"},
+				},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &lt.LoadTest{
+				ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "perf"},
+				Spec: lt.LoadTestSpec{
+					Count:   2,
+					Metrics: &lt.Metrics{Prometheus: &tt.prometheus},
+				},
+			}
+
+			scripts, err := workerScripts(v, tt.script)
+			if err != nil {
+				t.Fatalf("workerScripts() error = %v", err)
+			}
+
+			if scripts[0] != scripts[1] {
+				t.Errorf("workerScripts() = %q, want the same test script for every worker", scripts)
+			}
+
+			var got script
+			if err := yaml.Unmarshal([]byte(scripts[1]), &got); err != nil {
+				t.Fatalf("workerScripts() produced invalid YAML: %v", err)
+			}
+			if !reflect.DeepEqual(got.Config.Plugins.PublishMetrics, tt.want) {
+				t.Errorf("publish-metrics = %+v, want %+v", got.Config.Plugins.PublishMetrics, tt.want)
+			}
+
+			parsed, err := parseTestScript(scriptConfigMap(scripts[1]), TestScriptFilename)
+			if err != nil {
+				t.Fatalf("parseTestScript() error = %v", err)
+			}
+			if errs := parsed.validate(""); len(errs) > 0 {
+				t.Errorf("validate() = %v, want no errors", errs)
+			}
+		})
+	}
+}
+
+func TestWorkerScriptsPublishMetricsErrors(t *testing.T) {
+	v := &lt.LoadTest{
+		Spec: lt.LoadTestSpec{Metrics: &lt.Metrics{Prometheus: &lt.PrometheusMetrics{Pushgateway: "http://pushgateway:9091"}}},
+	}
+
+	_, err := workerScripts(v, "config:\n  plugins:\n    publish-metrics: statsd\n")
+	want := "line 3: config.plugins.publish-metrics must be a list"
+	if err == nil || err.Error() != want {
+		t.Errorf("workerScripts() error = %v, want %s", err, want)
+	}
+}
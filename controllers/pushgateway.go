@@ -0,0 +1,127 @@
+/*
+ * Copyright (c) 2022.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0.
+ *
+ * If a copy of the MPL was not distributed with
+ * this file, You can obtain one at
+ *
+ *   http://mozilla.org/MPL/2.0/
+ */
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	lt "github.com/artilleryio/artillery-operator/api/v1alpha1"
+	"github.com/go-logr/logr"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// PushgatewayFinalizer ensures metrics published to the Pushgateway are deleted along with their LoadTest.
+const PushgatewayFinalizer = "loadtest.artillery.io/pushgateway-cleanup"
+
+// defaultHTTPClient calls the Pushgateway API when the reconciler is not configured with an HTTP client.
+var defaultHTTPClient = &http.Client{Timeout: 10 * time.Second}
+
+// deletePushgatewayMetrics deletes the metrics published by every worker of a LoadTest from the Pushgateway.
+// Every worker publishes its metrics as a Pushgateway group using its worker ID as job ID.
+func (r *LoadTestReconciler) deletePushgatewayMetrics(ctx context.Context, v *lt.LoadTest) error {
+	httpClient := r.HTTPClient
+	if httpClient == nil {
+		httpClient = defaultHTTPClient
+	}
+
+	jobs := make([]string, workerCount(v))
+	for i := range jobs {
+		jobs[i] = workerID(v, strconv.Itoa(i))
+	}
+
+	return deletePushgatewayGroups(ctx, httpClient, v.Spec.Metrics.Prometheus.Pushgateway, jobs)
+}
+
+// deletePushgatewayGroups deletes the Pushgateway groups of the provided job IDs,
+// using the Pushgateway API: https://github.com/prometheus/pushgateway#api.
+// Deleting a group that doesn't exist succeeds.
+func deletePushgatewayGroups(ctx context.Context, httpClient *http.Client, pushgateway string, jobs []string) error {
+	for _, job := range jobs {
+		endpoint := strings.TrimSuffix(pushgateway, "/") + "/metrics/job/" + url.PathEscape(job)
+		req, err := http.NewRequestWithContext(ctx, http.MethodDelete, endpoint, nil)
+		if err != nil {
+			return err
+		}
+
+		resp, err := httpClient.Do(req)
+		if err != nil {
+			return err
+		}
+		_ = resp.Body.Close()
+
+		if resp.StatusCode < 200 || resp.StatusCode > 299 {
+			return fmt.Errorf("failed to delete Pushgateway group %s: %s", job, resp.Status)
+		}
+	}
+	return nil
+}
+
+// metricsRetentionDeadline returns when a finished LoadTest's metrics are due for deletion from the Pushgateway.
+// It returns false if there are no metrics to delete before the LoadTest is deleted.
+func metricsRetentionDeadline(v *lt.LoadTest) (time.Time, bool) {
+	if !publishesPrometheusMetrics(v) || v.Spec.Metrics.Prometheus.RetentionSeconds == nil ||
+		v.Status.CompletionTime == nil || v.Status.MetricsDeletionTime != nil {
+		return time.Time{}, false
+	}
+
+	retention := time.Duration(*v.Spec.Metrics.Prometheus.RetentionSeconds) * time.Second
+	return v.Status.CompletionTime.Add(retention), true
+}
+
+// metricsRetentionRemaining returns how long until a finished LoadTest's metrics are deleted from the Pushgateway,
+// or zero if there are none to delete.
+func metricsRetentionRemaining(v *lt.LoadTest, now time.Time) time.Duration {
+	deadline, ok := metricsRetentionDeadline(v)
+	if !ok {
+		return 0
+	}
+	if remaining := deadline.Sub(now); remaining > 0 {
+		return remaining
+	}
+	// Due now, requeue right away
+	return time.Second
+}
+
+// ensureMetricsRetention deletes a finished LoadTest's metrics from the Pushgateway
+// once they've been kept for longer than spec.metrics.prometheus.retentionSeconds.
+func (r *LoadTestReconciler) ensureMetricsRetention(ctx context.Context,
+	instance *lt.LoadTest,
+	logger logr.Logger) (*ctrl.Result, error) {
+	deadline, ok := metricsRetentionDeadline(instance)
+	if !ok || time.Now().Before(deadline) {
+		return nil, nil
+	}
+
+	if err := r.deletePushgatewayMetrics(ctx, instance); err != nil {
+		logger.Error(err, "Failed to delete metrics from Pushgateway")
+		r.Recorder.Eventf(instance, "Warning", "MetricsCleanupFailed", "Failed to delete Load Test metrics from Pushgateway: %s", err)
+		return &ctrl.Result{}, err
+	}
+
+	now := metav1.Now()
+	instance.Status.MetricsDeletionTime = &now
+	if err := r.Status().Update(ctx, instance); err != nil {
+		logger.Error(err, "Failed to update LoadTest status")
+		return &ctrl.Result{}, err
+	}
+
+	r.Recorder.Event(instance, "Normal", "MetricsDeleted", "Deleted Load Test metrics from Pushgateway")
+	return nil, nil
+}
@@ -0,0 +1,264 @@
+/*
+ * Copyright (c) 2022.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0.
+ *
+ * If a copy of the MPL was not distributed with
+ * this file, You can obtain one at
+ *
+ *   http://mozilla.org/MPL/2.0/
+ */
+
+package controllers
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+
+	lt "github.com/artilleryio/artillery-operator/api/v1alpha1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+// pushgateway is a Pushgateway stand-in recording the groups deleted through its API.
+type pushgateway struct {
+	*httptest.Server
+	mu      sync.Mutex
+	deleted []string
+}
+
+func newPushgateway(t *testing.T, status int) *pushgateway {
+	p := &pushgateway{}
+	p.Server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		if req.Method != http.MethodDelete {
+			w.WriteHeader(http.StatusMethodNotAllowed)
+			return
+		}
+		p.mu.Lock()
+		p.deleted = append(p.deleted, req.URL.EscapedPath())
+		p.mu.Unlock()
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(p.Close)
+	return p
+}
+
+func pushgatewayLoadTest(pushgateway string, count int) *lt.LoadTest {
+	return &lt.LoadTest{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "perf"},
+		Spec: lt.LoadTestSpec{
+			Count:   count,
+			Metrics: &lt.Metrics{Prometheus: &lt.PrometheusMetrics{Pushgateway: pushgateway}},
+		},
+	}
+}
+
+func TestDeletePushgatewayMetrics(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  int
+		wantErr bool
+	}{
+		{name: "accepted", status: http.StatusAccepted},
+		{name: "server error", status: http.StatusInternalServerError, wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPushgateway(t, tt.status)
+			r := &LoadTestReconciler{HTTPClient: p.Client()}
+
+			err := r.deletePushgatewayMetrics(context.Background(), pushgatewayLoadTest(p.URL+"/", 2))
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("deletePushgatewayMetrics() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			want := []string{"/metrics/job/nightly-0", "/metrics/job/nightly-1"}
+			if !reflect.DeepEqual(p.deleted, want) {
+				t.Errorf("deletePushgatewayMetrics() deleted = %v, want %v", p.deleted, want)
+			}
+		})
+	}
+}
+
+func TestMetricsRetentionRemaining(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	completed := metav1.NewTime(now.Add(-time.Minute))
+	retention := func(seconds int64) *int64 { return &seconds }
+
+	tests := []struct {
+		name      string
+		retention *int64
+		completed *metav1.Time
+		deleted   *metav1.Time
+		want      time.Duration
+	}{
+		{name: "no retention", completed: &completed},
+		{name: "running", retention: retention(300)},
+		{name: "retained", retention: retention(300), completed: &completed, want: 4 * time.Minute},
+		{name: "expired", retention: retention(30), completed: &completed, want: time.Second},
+		{name: "already deleted", retention: retention(30), completed: &completed, deleted: &completed},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := pushgatewayLoadTest("http://pushgateway:9091", 1)
+			v.Spec.Metrics.Prometheus.RetentionSeconds = tt.retention
+			v.Status.CompletionTime = tt.completed
+			v.Status.MetricsDeletionTime = tt.deleted
+
+			if got := metricsRetentionRemaining(v, now); got != tt.want {
+				t.Errorf("metricsRetentionRemaining() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestEnsureMetricsRetention(t *testing.T) {
+	p := newPushgateway(t, http.StatusAccepted)
+	v := pushgatewayLoadTest(p.URL, 1)
+	retention := int64(0)
+	v.Spec.Metrics.Prometheus.RetentionSeconds = &retention
+	completed := metav1.NewTime(time.Now().Add(-time.Minute))
+	v.Status.CompletionTime = &completed
+
+	recorder := record.NewFakeRecorder(10)
+	r := &LoadTestReconciler{
+		Client:     fake.NewClientBuilder().WithScheme(testScheme()).WithObjects(v).Build(),
+		Recorder:   recorder,
+		HTTPClient: p.Client(),
+	}
+
+	result, err := r.ensureMetricsRetention(context.Background(), v, logr.Discard())
+	if result != nil || err != nil {
+		t.Fatalf("ensureMetricsRetention() = %v, %v, want nil, nil", result, err)
+	}
+	if !reflect.DeepEqual(p.deleted, []string{"/metrics/job/nightly-0"}) {
+		t.Errorf("ensureMetricsRetention() deleted = %v", p.deleted)
+	}
+
+	found := &lt.LoadTest{}
+	if err := r.Get(context.Background(), types.NamespacedName{Name: v.Name, Namespace: v.Namespace}, found); err != nil {
+		t.Fatal(err)
+	}
+	if found.Status.MetricsDeletionTime == nil {
+		t.Errorf("ensureMetricsRetention() did not set status.metricsDeletionTime")
+	}
+	if len(recorder.Events) != 1 {
+		t.Errorf("ensureMetricsRetention() recorded %d events, want 1", len(recorder.Events))
+	}
+}
+
+func TestEnsureFinalizers(t *testing.T) {
+	tests := []struct {
+		name          string
+		status        int
+		deleting      bool
+		deletedFor    time.Duration
+		namespace     corev1.NamespacePhase
+		finalizers    []string
+		wantResult    bool
+		wantErr       bool
+		wantDeleted   []string
+		wantFinalizer bool
+	}{
+		{
+			name:          "adds the finalizer",
+			status:        http.StatusAccepted,
+			wantFinalizer: true,
+		},
+		{
+			name:        "deletes metrics and removes the finalizer",
+			status:      http.StatusAccepted,
+			deleting:    true,
+			finalizers:  []string{PushgatewayFinalizer},
+			wantResult:  true,
+			wantDeleted: []string{"/metrics/job/nightly-0"},
+		},
+		{
+			name:          "keeps the finalizer when deleting metrics fails",
+			status:        http.StatusServiceUnavailable,
+			deleting:      true,
+			finalizers:    []string{PushgatewayFinalizer},
+			wantResult:    true,
+			wantErr:       true,
+			wantDeleted:   []string{"/metrics/job/nightly-0"},
+			wantFinalizer: true,
+		},
+		{
+			name:        "skips deleting metrics in a terminating namespace",
+			status:      http.StatusServiceUnavailable,
+			deleting:    true,
+			namespace:   corev1.NamespaceTerminating,
+			finalizers:  []string{PushgatewayFinalizer},
+			wantResult:  true,
+			wantDeleted: []string{"/metrics/job/nightly-0"},
+		},
+		{
+			name:        "skips deleting metrics after the finalize timeout",
+			status:      http.StatusServiceUnavailable,
+			deleting:    true,
+			deletedFor:  finalizeTimeout + time.Minute,
+			finalizers:  []string{PushgatewayFinalizer},
+			wantResult:  true,
+			wantDeleted: []string{"/metrics/job/nightly-0"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			p := newPushgateway(t, tt.status)
+			v := pushgatewayLoadTest(p.URL, 1)
+			v.Finalizers = tt.finalizers
+			if tt.deleting {
+				deleted := metav1.NewTime(time.Now().Add(-tt.deletedFor))
+				v.DeletionTimestamp = &deleted
+			}
+			namespace := &corev1.Namespace{
+				ObjectMeta: metav1.ObjectMeta{Name: v.Namespace},
+				Status:     corev1.NamespaceStatus{Phase: corev1.NamespaceActive},
+			}
+			if tt.namespace != "" {
+				namespace.Status.Phase = tt.namespace
+			}
+
+			r := &LoadTestReconciler{
+				Client:     fake.NewClientBuilder().WithScheme(testScheme()).WithObjects(v, namespace).Build(),
+				Recorder:   record.NewFakeRecorder(10),
+				HTTPClient: p.Client(),
+			}
+
+			result, err := r.ensureFinalizers(context.Background(), v, logr.Discard())
+			if (result != nil) != tt.wantResult || (err != nil) != tt.wantErr {
+				t.Fatalf("ensureFinalizers() = %v, %v, want result %v, error %v", result, err, tt.wantResult, tt.wantErr)
+			}
+			if !reflect.DeepEqual(p.deleted, tt.wantDeleted) {
+				t.Errorf("ensureFinalizers() deleted = %v, want %v", p.deleted, tt.wantDeleted)
+			}
+
+			// Removing the last finalizer of a deleted LoadTest deletes it
+			found := &lt.LoadTest{}
+			if err := r.Get(context.Background(), types.NamespacedName{Name: v.Name, Namespace: v.Namespace}, found); err != nil && !errors.IsNotFound(err) {
+				t.Fatal(err)
+			}
+			if got := controllerutil.ContainsFinalizer(found, PushgatewayFinalizer); got != tt.wantFinalizer {
+				t.Errorf("ensureFinalizers() finalizer = %v, want %v", got, tt.wantFinalizer)
+			}
+		})
+	}
+}
@@ -0,0 +1,265 @@
+/*
+ * Copyright (c) 2022.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0.
+ *
+ * If a copy of the MPL was not distributed with
+ * this file, You can obtain one at
+ *
+ *   http://mozilla.org/MPL/2.0/
+ */
+
+package controllers
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	lt "github.com/artilleryio/artillery-operator/api/v1alpha1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+// reportLogTailLines the number of log lines read from the end of a worker's logs to find its summary report.
+const reportLogTailLines int64 = 1000
+
+var (
+	// summaryReportHeader matches the header printed by Artillery before a worker's final summary report.
+	summaryReportHeader = regexp.MustCompile(`^Summary report @`)
+	// reportMetric matches a counter or rate metric, e.g. "http.requests: ........ 180".
+	reportMetric = regexp.MustCompile(`^(\S.*?):\s+\.*\s*(\S+)$`)
+	// reportSummary matches the start of a summary metric, e.g. "http.response_time:".
+	reportSummary = regexp.MustCompile(`^(\S.*):$`)
+	// reportSummaryStat matches a summary metric's statistic, e.g. "  p95: ....... 12.1".
+	reportSummaryStat = regexp.MustCompile(`^\s+(\w+):\s+\.*\s*(\S+)$`)
+)
+
+// workerReport holds the metrics found in a worker's final Artillery summary report.
+type workerReport struct {
+	counters  map[string]int64
+	rates     map[string]int64
+	summaries map[string]map[string]float64
+}
+
+// parseSummaryReport parses the last summary report printed by an Artillery worker.
+// Artillery prints its summary report once all virtual users have finished, e.g.
+//
+//	Summary report @ 15:19:03(+0000)
+//	--------------------------------
+//
+//	http.codes.200: ........................ 180
+//	http.request_rate: ..................... 3/sec
+//	http.response_time:
+//	  min: ................................. 0
+//	  p95: ................................. 1
+func parseSummaryReport(logs io.Reader) (*workerReport, error) {
+	var (
+		report  *workerReport
+		summary string
+	)
+
+	scanner := bufio.NewScanner(logs)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), " \r")
+
+		if summaryReportHeader.MatchString(line) {
+			// Only the last summary report counts
+			report = &workerReport{
+				counters:  map[string]int64{},
+				rates:     map[string]int64{},
+				summaries: map[string]map[string]float64{},
+			}
+			summary = ""
+			continue
+		}
+		if report == nil {
+			continue
+		}
+
+		switch {
+		case summary != "" && reportSummaryStat.MatchString(line):
+			m := reportSummaryStat.FindStringSubmatch(line)
+			if value, err := strconv.ParseFloat(m[2], 64); err == nil {
+				report.summaries[summary][m[1]] = value
+			}
+
+		case reportSummary.MatchString(line):
+			summary = reportSummary.FindStringSubmatch(line)[1]
+			report.summaries[summary] = map[string]float64{}
+
+		case reportMetric.MatchString(line):
+			summary = ""
+			m := reportMetric.FindStringSubmatch(line)
+			if rate := strings.TrimSuffix(m[2], "/sec"); rate != m[2] {
+				if value, err := strconv.ParseInt(rate, 10, 64); err == nil {
+					report.rates[m[1]] = value
+				}
+			} else if value, err := strconv.ParseInt(m[2], 10, 64); err == nil {
+				report.counters[m[1]] = value
+			}
+
+		default:
+			summary = ""
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if report == nil {
+		return nil, fmt.Errorf("no summary report found")
+	}
+	return report, nil
+}
+
+// aggregateReports merges the final summary reports of all workers into a LoadTestReport.
+func aggregateReports(reports []*workerReport) *lt.LoadTestReport {
+	out := &lt.LoadTestReport{
+		Workers:      int32(len(reports)),
+		ErrorsByCode: map[string]int64{},
+		Codes:        map[string]int64{},
+	}
+
+	var (
+		latencies []map[string]float64
+		weights   []int64
+	)
+
+	for _, r := range reports {
+		out.Requests += r.counters["http.requests"]
+		out.Responses += r.counters["http.responses"]
+		out.RequestRate += r.rates["http.request_rate"]
+		out.VUsers.Created += r.counters["vusers.created"]
+		out.VUsers.Completed += r.counters["vusers.completed"]
+		out.VUsers.Failed += r.counters["vusers.failed"]
+
+		for name, value := range r.counters {
+			switch {
+			case strings.HasPrefix(name, "errors."):
+				out.Errors += value
+				out.ErrorsByCode[strings.TrimPrefix(name, "errors.")] += value
+			case strings.HasPrefix(name, "http.codes."):
+				out.Codes[strings.TrimPrefix(name, "http.codes.")] += value
+			}
+		}
+
+		if latency, ok := r.summaries["http.response_time"]; ok {
+			latencies = append(latencies, latency)
+			weights = append(weights, r.counters["http.responses"])
+		}
+	}
+
+	if len(latencies) > 0 {
+		out.Latency = lt.LoadTestLatency{
+			Min: msDuration(minStat(latencies, "min")),
+			Max: msDuration(maxStat(latencies, "max")),
+			P50: msDuration(weightedStat(latencies, weights, "median")),
+			P95: msDuration(weightedStat(latencies, weights, "p95")),
+			P99: msDuration(weightedStat(latencies, weights, "p99")),
+		}
+	}
+
+	if len(out.ErrorsByCode) == 0 {
+		out.ErrorsByCode = nil
+	}
+	if len(out.Codes) == 0 {
+		out.Codes = nil
+	}
+
+	return out
+}
+
+func minStat(stats []map[string]float64, name string) float64 {
+	out := stats[0][name]
+	for _, s := range stats[1:] {
+		if s[name] < out {
+			out = s[name]
+		}
+	}
+	return out
+}
+
+func maxStat(stats []map[string]float64, name string) float64 {
+	out := stats[0][name]
+	for _, s := range stats[1:] {
+		if s[name] > out {
+			out = s[name]
+		}
+	}
+	return out
+}
+
+// weightedStat returns the mean of a statistic across workers weighted by the provided weights.
+// If no weights are available, the plain mean is used.
+func weightedStat(stats []map[string]float64, weights []int64, name string) float64 {
+	var sum, total float64
+	for i, s := range stats {
+		sum += s[name] * float64(weights[i])
+		total += float64(weights[i])
+	}
+	if total > 0 {
+		return sum / total
+	}
+
+	for _, s := range stats {
+		sum += s[name]
+	}
+	return sum / float64(len(stats))
+}
+
+// msDuration converts a value in milliseconds, as reported by Artillery, to a Duration.
+func msDuration(ms float64) metav1.Duration {
+	return metav1.Duration{Duration: time.Duration(ms * float64(time.Millisecond)).Round(100 * time.Microsecond)}
+}
+
+// configureReport aggregates the final summary reports found in the logs of the LoadTest's worker Pods.
+// Workers without a summary report, e.g. because they crashed, are skipped.
+func (r *LoadTestReconciler) configureReport(ctx context.Context, v *lt.LoadTest, logger logr.Logger) error {
+	if r.KubeClient == nil {
+		return nil
+	}
+
+	podList, err := getPods(ctx, v, r.Client)
+	if err != nil {
+		return err
+	}
+
+	var reports []*workerReport
+	for _, pod := range podList.Items {
+		report, err := r.workerReport(ctx, pod)
+		if err != nil {
+			logger.Info("Could not read Load Test worker report", "Pod.Name", pod.Name, "Error", err.Error())
+			continue
+		}
+		reports = append(reports, report)
+	}
+
+	if len(reports) > 0 {
+		v.Status.Report = aggregateReports(reports)
+	}
+	return nil
+}
+
+// workerReport reads the final summary report from a worker Pod's logs.
+func (r *LoadTestReconciler) workerReport(ctx context.Context, pod corev1.Pod) (*workerReport, error) {
+	tailLines := reportLogTailLines
+	logs, err := r.KubeClient.CoreV1().Pods(pod.Namespace).GetLogs(pod.Name, &corev1.PodLogOptions{
+		TailLines: &tailLines,
+	}).Stream(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer func() {
+		_ = logs.Close()
+	}()
+
+	return parseSummaryReport(logs)
+}
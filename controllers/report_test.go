@@ -0,0 +1,163 @@
+/*
+ * Copyright (c) 2022.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0.
+ *
+ * If a copy of the MPL was not distributed with
+ * this file, You can obtain one at
+ *
+ *   http://mozilla.org/MPL/2.0/
+ */
+
+package controllers
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	lt "github.com/artilleryio/artillery-operator/api/v1alpha1"
+	"k8s.io/client-go/util/jsonpath"
+)
+
+const workerLogs = `Phase started: Warm up (index: 0, duration: 60s) 15:18:03(+0000)
+
+Report @ 15:18:13(+0000)
+http.requests: ................................................. 30
+http.responses: ................................................ 30
+
+All VUs finished. Total time: 1 minute, 0 seconds
+
+--------------------------------
+Summary report @ 15:19:03(+0000)
+--------------------------------
+
+errors.ETIMEDOUT: .............................................. 2
+http.codes.200: ................................................ 170
+http.codes.500: ................................................ 8
+http.request_rate: ............................................. 3/sec
+http.requests: ................................................. 180
+http.response_time:
+  min: ......................................................... 1
+  max: ......................................................... 250
+  median: ...................................................... 10.5
+  p95: ......................................................... 40
+  p99: ......................................................... 120
+http.responses: ................................................ 178
+vusers.completed: .............................................. 58
+vusers.created: ................................................ 60
+vusers.failed: ................................................. 2
+`
+
+func TestParseSummaryReport(t *testing.T) {
+	report, err := parseSummaryReport(strings.NewReader(workerLogs))
+	if err != nil {
+		t.Fatalf("parseSummaryReport() error = %v", err)
+	}
+
+	if got := report.counters["http.requests"]; got != 180 {
+		t.Errorf("http.requests = %d, want 180", got)
+	}
+	if got := report.counters["errors.ETIMEDOUT"]; got != 2 {
+		t.Errorf("errors.ETIMEDOUT = %d, want 2", got)
+	}
+	if got := report.rates["http.request_rate"]; got != 3 {
+		t.Errorf("http.request_rate = %d, want 3", got)
+	}
+	if got := report.summaries["http.response_time"]["p99"]; got != 120 {
+		t.Errorf("http.response_time p99 = %v, want 120", got)
+	}
+	if got := report.counters["vusers.failed"]; got != 2 {
+		t.Errorf("vusers.failed = %d, want 2", got)
+	}
+}
+
+func TestParseSummaryReportMissing(t *testing.T) {
+	if _, err := parseSummaryReport(strings.NewReader("Phase started: Warm up\n")); err == nil {
+		t.Errorf("parseSummaryReport() expected an error for logs without a summary report")
+	}
+}
+
+func TestAggregateReports(t *testing.T) {
+	first, err := parseSummaryReport(strings.NewReader(workerLogs))
+	if err != nil {
+		t.Fatalf("parseSummaryReport() error = %v", err)
+	}
+	second := &workerReport{
+		counters: map[string]int64{
+			"http.requests":    22,
+			"http.responses":   22,
+			"http.codes.200":   22,
+			"vusers.created":   10,
+			"vusers.completed": 10,
+		},
+		rates: map[string]int64{"http.request_rate": 1},
+		summaries: map[string]map[string]float64{
+			"http.response_time": {"min": 0.5, "max": 100, "median": 8, "p95": 20, "p99": 20},
+		},
+	}
+
+	report := aggregateReports([]*workerReport{first, second})
+
+	if report.Workers != 2 {
+		t.Errorf("Workers = %d, want 2", report.Workers)
+	}
+	if report.Requests != 202 || report.Responses != 200 {
+		t.Errorf("Requests/Responses = %d/%d, want 202/200", report.Requests, report.Responses)
+	}
+	if report.RequestRate != 4 {
+		t.Errorf("RequestRate = %d, want 4", report.RequestRate)
+	}
+	if report.Errors != 2 || report.ErrorsByCode["ETIMEDOUT"] != 2 {
+		t.Errorf("Errors = %d, ErrorsByCode = %v, want 2 ETIMEDOUT", report.Errors, report.ErrorsByCode)
+	}
+	if report.Codes["200"] != 192 || report.Codes["500"] != 8 {
+		t.Errorf("Codes = %v, want 200: 192, 500: 8", report.Codes)
+	}
+	if report.VUsers.Created != 70 || report.VUsers.Failed != 2 {
+		t.Errorf("VUsers = %+v, want 70 created and 2 failed", report.VUsers)
+	}
+
+	if got := report.Latency.Min.Duration; got != 500*time.Microsecond {
+		t.Errorf("Latency.Min = %v, want 500µs", got)
+	}
+	if got := report.Latency.Max.Duration; got != 250*time.Millisecond {
+		t.Errorf("Latency.Max = %v, want 250ms", got)
+	}
+	// (178 * 120 + 22 * 20) / 200 = 109ms
+	if got := report.Latency.P99.Duration; got != 109*time.Millisecond {
+		t.Errorf("Latency.P99 = %v, want 109ms", got)
+	}
+}
+
+func TestReportVUsersColumns(t *testing.T) {
+	report, err := parseSummaryReport(strings.NewReader(workerLogs))
+	if err != nil {
+		t.Fatalf("parseSummaryReport() error = %v", err)
+	}
+	v := &lt.LoadTest{Status: lt.LoadTestStatus{Report: aggregateReports([]*workerReport{report})}}
+
+	// JSONPaths of the VUsers printer columns
+	columns := map[string]string{
+		"{.status.report.vusers.created}":   "60",
+		"{.status.report.vusers.completed}": "58",
+		"{.status.report.vusers.failed}":    "2",
+	}
+	for path, want := range columns {
+		j := jsonpath.New(path)
+		if err := j.Parse(path); err != nil {
+			t.Fatal(err)
+		}
+
+		var out bytes.Buffer
+		if err := j.Execute(&out, v); err != nil {
+			t.Errorf("column %s error = %v", path, err)
+			continue
+		}
+		if out.String() != want {
+			t.Errorf("column %s = %q, want %q", path, out.String(), want)
+		}
+	}
+}
@@ -12,6 +12,8 @@
 
 package controllers
 
+import "time"
+
 const (
 	// AppName the controller and CLI app name.
 	AppName = "artillery-operator"
@@ -19,8 +21,14 @@ const (
 	// Version controller version.
 	Version = "alpha"
 
-	// WorkerImage the Artillery image used by workers to run load tests.
-	WorkerImage = "artilleryio/artillery:latest"
+	// DefaultWorkerImage the default Artillery image used by workers to run load tests.
+	// It can be overridden operator-wide using the --worker-image flag or the ARTILLERY_WORKER_IMAGE env var,
+	// and per LoadTest using the spec.image field.
+	DefaultWorkerImage = "artilleryio/artillery:latest"
+
+	// DefaultPendingTimeout the maximum time a worker pod can stay pending before its LoadTest fails.
+	// It can be overridden operator-wide using the --worker-pending-timeout flag or the WORKER_PENDING_TIMEOUT env var.
+	DefaultPendingTimeout = 5 * time.Minute
 
 	// TestScriptVol the volume used by created LoadTest Pods to load the test script ConfigMap.
 	TestScriptVol = "test-script"
@@ -0,0 +1,218 @@
+/*
+ * Copyright (c) 2022.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0.
+ *
+ * If a copy of the MPL was not distributed with
+ * this file, You can obtain one at
+ *
+ *   http://mozilla.org/MPL/2.0/
+ */
+
+package controllers
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strconv"
+
+	lt "github.com/artilleryio/artillery-operator/api/v1alpha1"
+	"github.com/go-logr/logr"
+	core "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// snapshotConfigMapName returns the name of the ConfigMap holding the LoadTest's test script snapshot.
+func snapshotConfigMapName(v *lt.LoadTest) string {
+	return v.Name + "-snapshot"
+}
+
+// hasSnapshot returns whether the LoadTest's test script snapshot was taken.
+func hasSnapshot(v *lt.LoadTest) bool {
+	return v.Status.TestScript != nil && v.Status.TestScript.Snapshot != nil
+}
+
+// launched returns whether the LoadTest's snapshot was taken or its workers started, or it finished.
+// From then on, its source ConfigMaps and Secrets no longer affect it and are not checked again.
+func launched(v *lt.LoadTest) bool {
+	return hasSnapshot(v) || v.Status.StartTime != nil || v.Status.CompletionTime != nil
+}
+
+// ensureSnapshot copies the test script along with any external payload and processor files
+// into an immutable ConfigMap owned by the LoadTest, before its workers are created.
+// This ensures every worker runs the same files, even if the source ConfigMaps are updated
+// while workers are starting. The snapshot's content hash is recorded in the LoadTest's status,
+// along with the revision of the test script it was taken from.
+func (r *LoadTestReconciler) ensureSnapshot(ctx context.Context,
+	instance *lt.LoadTest,
+	logger logr.Logger) (*ctrl.Result, error) {
+	if launched(instance) {
+		return nil, nil
+	}
+
+	snapshot, testScript, err := r.snapshot(ctx, instance)
+	if err != nil {
+		logger.Error(err, "Failed to read test script ConfigMaps")
+		return &ctrl.Result{}, err
+	}
+	if msg, ok := snapshotTooLarge(snapshot); ok {
+		return r.rejectLoadTest(ctx, instance, logger, ReasonSnapshotTooLarge, "Load Test "+msg)
+	}
+
+	err = r.Create(ctx, snapshot)
+	if err != nil && errors.IsAlreadyExists(err) {
+		// Taken by a previous reconcile whose status update failed
+		found := &core.ConfigMap{}
+		if err := r.Get(ctx, types.NamespacedName{Name: snapshot.Name, Namespace: snapshot.Namespace}, found); err != nil {
+			logger.Error(err, "Failed to get test script snapshot", "ConfigMap.Namespace", snapshot.Namespace, "ConfigMap.Name", snapshot.Name)
+			return &ctrl.Result{}, err
+		}
+		if !metav1.IsControlledBy(found, instance) {
+			return r.rejectLoadTest(ctx, instance, logger, ReasonSnapshotConflict,
+				fmt.Sprintf("Load Test test script snapshot cannot be taken, ConfigMap %s already exists and is not owned by the Load Test", found.Name))
+		}
+		snapshot = found
+	} else if err != nil {
+		logger.Error(err, "Failed to create test script snapshot", "ConfigMap.Namespace", snapshot.Namespace, "ConfigMap.Name", snapshot.Name)
+		return &ctrl.Result{}, err
+	} else {
+		r.Recorder.Eventf(instance, "Normal", "Created", "Created Load Test test script snapshot: %s", snapshot.Name)
+	}
+
+	// Persisted before workers are created, so that the LoadTest is launched from then on
+	// even if its next status update fails, see launched
+	instance.Status.TestScript = testScriptRevision(testScript, testScriptKey(instance))
+	instance.Status.TestScript.Snapshot = &lt.LoadTestSnapshotStatus{
+		ConfigMap: snapshot.Name,
+		Hash:      snapshotHash(snapshot),
+	}
+	if err := r.Status().Update(ctx, instance); err != nil {
+		logger.Error(err, "Failed to update LoadTest status")
+		return &ctrl.Result{}, err
+	}
+
+	return nil, nil
+}
+
+// snapshot creates an immutable ConfigMap holding every key of the test script and external ConfigMaps.
+// It also returns the test script ConfigMap the snapshot was taken from.
+func (r *LoadTestReconciler) snapshot(ctx context.Context, v *lt.LoadTest) (*core.ConfigMap, *core.ConfigMap, error) {
+	testScript, err := r.testScriptConfigMap(ctx, v)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sources := []*core.ConfigMap{testScript}
+	for _, ref := range externalConfigRefs(v) {
+		found := &core.ConfigMap{}
+		if err := r.Get(ctx, types.NamespacedName{Name: ref.configMap, Namespace: v.Namespace}, found); err != nil {
+			return nil, nil, err
+		}
+		sources = append(sources, found)
+	}
+
+	snapshot, err := snapshotConfigMap(v, sources)
+	if err != nil {
+		return nil, nil, err
+	}
+	if err := ctrl.SetControllerReference(v, snapshot, r.Scheme); err != nil {
+		return nil, nil, err
+	}
+	return snapshot, testScript, nil
+}
+
+// snapshotConfigMap creates the snapshot of the provided test script and external ConfigMaps, in that order.
+// External ConfigMap keys overwrite test script keys of the same name.
+// When workers run their own test script, e.g. when load phases are split across workers,
+// the snapshot also holds every distinct worker test script, stored by the first worker running it.
+// Following workers running the same test script read it from that key, see workerScriptItems.
+func snapshotConfigMap(v *lt.LoadTest, sources []*core.ConfigMap) (*core.ConfigMap, error) {
+	immutable := true
+	snapshot := &core.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      snapshotConfigMapName(v),
+			Namespace: v.Namespace,
+			Labels:    labels(v, "test-script-snapshot"),
+		},
+		Data:       map[string]string{},
+		BinaryData: map[string][]byte{},
+		Immutable:  &immutable,
+	}
+	for _, cm := range sources {
+		for k, d := range cm.Data {
+			snapshot.Data[k] = d
+		}
+		for k, d := range cm.BinaryData {
+			snapshot.BinaryData[k] = d
+		}
+	}
+
+	if hasWorkerScripts(v) {
+		key := testScriptKey(v)
+		scripts, err := workerScripts(v, snapshot.Data[key])
+		if err != nil {
+			return nil, err
+		}
+		for i, script := range scripts {
+			if i > 0 && script == scripts[i-1] {
+				continue
+			}
+			snapshot.Data[workerScriptKey(strconv.Itoa(i), key)] = script
+		}
+	}
+
+	return snapshot, nil
+}
+
+// snapshotSize returns the size of a snapshot's data, which the API server limits to 1MiB like any ConfigMap.
+func snapshotSize(cm *core.ConfigMap) int {
+	size := 0
+	for _, d := range cm.Data {
+		size += len(d)
+	}
+	for _, d := range cm.BinaryData {
+		size += len(d)
+	}
+	return size
+}
+
+// snapshotTooLarge returns a message explaining why a snapshot can't be stored, if it exceeds the ConfigMap size limit.
+func snapshotTooLarge(cm *core.ConfigMap) (string, bool) {
+	if size := snapshotSize(cm); size > core.MaxSecretSize {
+		return fmt.Sprintf("test script snapshot is %d bytes, exceeding the %d bytes ConfigMap size limit", size, core.MaxSecretSize), true
+	}
+	return "", false
+}
+
+// snapshotHash hashes every key and value held by the snapshot, in key order.
+func snapshotHash(cm *core.ConfigMap) string {
+	files := make(map[string][]byte, len(cm.Data)+len(cm.BinaryData))
+	for k, d := range cm.Data {
+		files[k] = []byte(d)
+	}
+	for k, d := range cm.BinaryData {
+		files[k] = d
+	}
+
+	keys := make([]string, 0, len(files))
+	for k := range files {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	h := sha256.New()
+	for _, k := range keys {
+		h.Write([]byte(k))
+		h.Write([]byte{0})
+		h.Write(files[k])
+		h.Write([]byte{0})
+	}
+	return "sha256:" + hex.EncodeToString(h.Sum(nil))
+}
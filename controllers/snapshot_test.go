@@ -0,0 +1,306 @@
+/*
+ * Copyright (c) 2022.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0.
+ *
+ * If a copy of the MPL was not distributed with
+ * this file, You can obtain one at
+ *
+ *   http://mozilla.org/MPL/2.0/
+ */
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"sort"
+	"strings"
+	"testing"
+
+	lt "github.com/artilleryio/artillery-operator/api/v1alpha1"
+	"github.com/go-logr/logr"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+	"sigs.k8s.io/controller-runtime/pkg/controller/controllerutil"
+)
+
+func TestSnapshot(t *testing.T) {
+	scheme := testScheme()
+
+	testScript := scriptConfigMap(validTestScript)
+	testScript.ObjectMeta = metav1.ObjectMeta{Name: "test-script", Namespace: "default"}
+	users := &core.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{Name: "users-csv", Namespace: "default"},
+		Data:       map[string]string{"users.csv": "alice\nbob\n"},
+		BinaryData: map[string][]byte{"avatar.png": {0x89, 0x50}},
+	}
+
+	r := &LoadTestReconciler{
+		Client: fake.NewClientBuilder().WithScheme(scheme).WithObjects(testScript, users).Build(),
+		Scheme: scheme,
+	}
+	v := &lt.LoadTest{
+		ObjectMeta: metav1.ObjectMeta{Name: "basic-test", Namespace: "default", UID: "uid"},
+		Spec: lt.LoadTestSpec{
+			TestScript: lt.TestScript{
+				Config:   lt.Config{ConfigMap: "test-script"},
+				External: &lt.External{Payload: &lt.Payload{ConfigMaps: []string{"users-csv"}}},
+			},
+		},
+	}
+
+	snapshot, source, err := r.snapshot(context.Background(), v)
+	if err != nil {
+		t.Fatalf("snapshot() error = %v", err)
+	}
+	if source.Name != testScript.Name {
+		t.Errorf("snapshot() test script = %q, want %q", source.Name, testScript.Name)
+	}
+
+	if snapshot.Name != "basic-test-snapshot" {
+		t.Errorf("snapshot() name = %q, want %q", snapshot.Name, "basic-test-snapshot")
+	}
+	if snapshot.Immutable == nil || !*snapshot.Immutable {
+		t.Errorf("snapshot() is not immutable")
+	}
+	if !metav1.IsControlledBy(snapshot, v) {
+		t.Errorf("snapshot() is not owned by the LoadTest")
+	}
+
+	wantData := map[string]string{TestScriptFilename: validTestScript, "users.csv": "alice\nbob\n"}
+	if !reflect.DeepEqual(snapshot.Data, wantData) {
+		t.Errorf("snapshot() data = %v, want %v", snapshot.Data, wantData)
+	}
+	if !reflect.DeepEqual(snapshot.BinaryData, users.BinaryData) {
+		t.Errorf("snapshot() binary data = %v, want %v", snapshot.BinaryData, users.BinaryData)
+	}
+}
+
+func TestSnapshotHash(t *testing.T) {
+	cm := &core.ConfigMap{Data: map[string]string{"a": "1", "b": "2"}}
+	same := &core.ConfigMap{Data: map[string]string{"b": "2", "a": "1"}}
+	moved := &core.ConfigMap{Data: map[string]string{"a": "12"}}
+	binary := &core.ConfigMap{Data: map[string]string{"a": "1"}, BinaryData: map[string][]byte{"b": []byte("2")}}
+
+	if snapshotHash(cm) != snapshotHash(same) {
+		t.Errorf("snapshotHash() differs for the same content")
+	}
+	if snapshotHash(cm) == snapshotHash(moved) {
+		t.Errorf("snapshotHash() is the same for different keys")
+	}
+	if snapshotHash(cm) != snapshotHash(binary) {
+		t.Errorf("snapshotHash() differs for the same files held as binary data")
+	}
+}
+
+func TestPreLaunchChecksAfterLaunch(t *testing.T) {
+	// Every source object was deleted once the snapshot was taken
+	v := &lt.LoadTest{
+		ObjectMeta: metav1.ObjectMeta{Name: "basic-test", Namespace: "default"},
+		Spec: lt.LoadTestSpec{
+			Environment: "staging",
+			TestScript: lt.TestScript{
+				Config:   lt.Config{ConfigMap: "test-script"},
+				External: &lt.External{Payload: &lt.Payload{ConfigMaps: []string{"users-csv"}}},
+			},
+			Env: []core.EnvVar{{
+				Name: "API_TOKEN",
+				ValueFrom: &core.EnvVarSource{SecretKeyRef: &core.SecretKeySelector{
+					LocalObjectReference: core.LocalObjectReference{Name: "api"},
+					Key:                  "token",
+				}},
+			}},
+		},
+		Status: lt.LoadTestStatus{
+			Phase: lt.LoadTestPhaseSucceeded,
+			TestScript: &lt.LoadTestScriptStatus{
+				Snapshot: &lt.LoadTestSnapshotStatus{ConfigMap: "basic-test-snapshot"},
+			},
+		},
+	}
+
+	r := &LoadTestReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(testScheme()).WithObjects(v).Build(),
+		Recorder: record.NewFakeRecorder(10),
+	}
+
+	checks := map[string]func(context.Context, *lt.LoadTest, logr.Logger) (*ctrl.Result, error){
+		"ensureTestScriptConfig": r.ensureTestScriptConfig,
+		"ensureExternalConfig":   r.ensureExternalConfig,
+		"ensureEnvConfig":        r.ensureEnvConfig,
+		"ensureEnvironment":      r.ensureEnvironment,
+	}
+	for name, check := range checks {
+		if result, err := check(context.Background(), v, logr.Discard()); result != nil || err != nil {
+			t.Errorf("%s() = %v, %v, want nil, nil", name, result, err)
+		}
+	}
+
+	if v.Status.Phase != lt.LoadTestPhaseSucceeded || len(v.Status.Conditions) > 0 {
+		t.Errorf("status changed to phase %s, conditions %v", v.Status.Phase, v.Status.Conditions)
+	}
+}
+
+func TestEnsureSnapshotExisting(t *testing.T) {
+	tests := []struct {
+		name       string
+		owned      bool
+		wantResult bool
+	}{
+		{name: "taken by a previous reconcile", owned: true},
+		{name: "not owned by the LoadTest", wantResult: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			scheme := testScheme()
+			testScript := scriptConfigMap(validTestScript)
+			testScript.ObjectMeta = metav1.ObjectMeta{Name: "test-script", Namespace: "default"}
+			v := &lt.LoadTest{
+				ObjectMeta: metav1.ObjectMeta{Name: "basic-test", Namespace: "default", UID: "uid"},
+				Spec:       lt.LoadTestSpec{TestScript: lt.TestScript{Config: lt.Config{ConfigMap: "test-script"}}},
+			}
+			existing := &core.ConfigMap{
+				ObjectMeta: metav1.ObjectMeta{Name: "basic-test-snapshot", Namespace: "default"},
+				Data:       map[string]string{TestScriptFilename: "config: {}"},
+			}
+			if tt.owned {
+				if err := controllerutil.SetControllerReference(v, existing, scheme); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			r := &LoadTestReconciler{
+				Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(v, testScript, existing).Build(),
+				Scheme:   scheme,
+				Recorder: record.NewFakeRecorder(10),
+			}
+
+			result, err := r.ensureSnapshot(context.Background(), v, logr.Discard())
+			if err != nil || (result != nil) != tt.wantResult {
+				t.Fatalf("ensureSnapshot() = %v, %v, want result %v", result, err, tt.wantResult)
+			}
+
+			failed := conditionsMap(v.Status.Conditions)[lt.LoadTestFailed]
+			if tt.wantResult {
+				if failed.Reason != ReasonSnapshotConflict || hasSnapshot(v) {
+					t.Errorf("ensureSnapshot() Failed reason = %q, snapshot = %v, want a rejected LoadTest", failed.Reason, v.Status.TestScript)
+				}
+				return
+			}
+			if !hasSnapshot(v) || v.Status.TestScript.Snapshot.Hash != snapshotHash(existing) {
+				t.Errorf("ensureSnapshot() did not record the existing snapshot, status = %v", v.Status.TestScript)
+			}
+
+			// Persisted before workers are created
+			persisted := &lt.LoadTest{}
+			if err := r.Get(context.Background(), types.NamespacedName{Name: v.Name, Namespace: v.Namespace}, persisted); err != nil {
+				t.Fatal(err)
+			}
+			if !launched(persisted) || persisted.Status.TestScript.ConfigMap != testScript.Name {
+				t.Errorf("ensureSnapshot() persisted status = %+v, want a launched LoadTest", persisted.Status.TestScript)
+			}
+		})
+	}
+}
+
+func TestSnapshotWorkerScripts(t *testing.T) {
+	testScript := scriptConfigMap(splitTestScriptFixture)
+
+	tests := []struct {
+		name      string
+		spec      lt.LoadTestSpec
+		wantKeys  []string
+		wantItems []core.KeyToPath
+	}{
+		{
+			name:     "published metrics",
+			spec:     lt.LoadTestSpec{Count: MaxCount, Metrics: &lt.Metrics{Prometheus: &lt.PrometheusMetrics{Pushgateway: "http://pushgateway:9091"}}},
+			wantKeys: []string{"worker-0-test-script.yaml"},
+		},
+		{
+			name:     "split",
+			spec:     lt.LoadTestSpec{Count: 4, Distribution: lt.DistributionSplit, Environment: "staging"},
+			wantKeys: []string{"worker-0-test-script.yaml", "worker-1-test-script.yaml", "worker-2-test-script.yaml"},
+			wantItems: []core.KeyToPath{
+				{Key: "worker-2-test-script.yaml", Path: "worker-3-test-script.yaml"},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &lt.LoadTest{ObjectMeta: metav1.ObjectMeta{Name: "basic-test", Namespace: "default"}, Spec: tt.spec}
+			snapshot, err := snapshotConfigMap(v, []*core.ConfigMap{testScript})
+			if err != nil {
+				t.Fatalf("snapshotConfigMap() error = %v", err)
+			}
+
+			var keys []string
+			for k := range snapshot.Data {
+				if k != TestScriptFilename {
+					keys = append(keys, k)
+				}
+			}
+			sort.Strings(keys)
+			if !reflect.DeepEqual(keys, tt.wantKeys) {
+				t.Errorf("snapshotConfigMap() worker script keys = %v, want %v", keys, tt.wantKeys)
+			}
+			if _, ok := snapshotTooLarge(snapshot); ok {
+				t.Errorf("snapshotConfigMap() is %d bytes, want it to fit in a ConfigMap", snapshotSize(snapshot))
+			}
+
+			items := workerScriptItems(v, snapshot)
+			if len(items) != workerCount(v)-len(tt.wantKeys) {
+				t.Fatalf("workerScriptItems() = %d items, want %d", len(items), workerCount(v)-len(tt.wantKeys))
+			}
+			if tt.wantItems != nil && !reflect.DeepEqual(items, tt.wantItems) {
+				t.Errorf("workerScriptItems() = %v, want %v", items, tt.wantItems)
+			}
+			for _, item := range items {
+				if _, ok := snapshot.Data[item.Key]; !ok {
+					t.Errorf("workerScriptItems() maps %s to missing key %s", item.Path, item.Key)
+				}
+			}
+		})
+	}
+}
+
+func TestEnsureSnapshotTooLarge(t *testing.T) {
+	scheme := testScheme()
+	testScript := scriptConfigMap(validTestScript)
+	testScript.ObjectMeta = metav1.ObjectMeta{Name: "test-script", Namespace: "default"}
+	payloads := []*core.ConfigMap{
+		{ObjectMeta: metav1.ObjectMeta{Name: "users-csv", Namespace: "default"}, Data: map[string]string{"users.csv": strings.Repeat("a", 600*1024)}},
+		{ObjectMeta: metav1.ObjectMeta{Name: "items-csv", Namespace: "default"}, Data: map[string]string{"items.csv": strings.Repeat("b", 600*1024)}},
+	}
+	v := &lt.LoadTest{
+		ObjectMeta: metav1.ObjectMeta{Name: "basic-test", Namespace: "default", UID: "uid"},
+		Spec: lt.LoadTestSpec{TestScript: lt.TestScript{
+			Config:   lt.Config{ConfigMap: "test-script"},
+			External: &lt.External{Payload: &lt.Payload{ConfigMaps: []string{"users-csv", "items-csv"}}},
+		}},
+	}
+
+	r := &LoadTestReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(scheme).WithObjects(v, testScript, payloads[0], payloads[1]).Build(),
+		Scheme:   scheme,
+		Recorder: record.NewFakeRecorder(10),
+	}
+
+	if result, err := r.ensureSnapshot(context.Background(), v, logr.Discard()); result == nil || err != nil {
+		t.Fatalf("ensureSnapshot() = %v, %v, want a rejected LoadTest", result, err)
+	}
+	if failed := conditionsMap(v.Status.Conditions)[lt.LoadTestFailed]; failed.Reason != ReasonSnapshotTooLarge {
+		t.Errorf("ensureSnapshot() Failed reason = %q, want %q", failed.Reason, ReasonSnapshotTooLarge)
+	}
+	if hasSnapshot(v) {
+		t.Errorf("ensureSnapshot() recorded a snapshot that was not taken")
+	}
+}
@@ -15,6 +15,8 @@ package controllers
 import (
 	"context"
 	"fmt"
+	"sort"
+	"strconv"
 	"time"
 
 	lt "github.com/artilleryio/artillery-operator/api/v1alpha1"
@@ -39,6 +41,50 @@ const (
 	LoadTestInactive ObservedStatus = iota
 	LoadTestActive
 	LoadTestCompleted
+	LoadTestSuspended
+	LoadTestFailed
+)
+
+// Reasons used by LoadTest conditions to explain why a LoadTest failed.
+const (
+	// ReasonWorkerFailed means one or more workers exited with an error.
+	ReasonWorkerFailed = "WorkerFailed"
+	// ReasonMissingTestScript means the test script ConfigMap does not exist.
+	ReasonMissingTestScript = "MissingTestScript"
+	// ReasonMissingExternalConfig means an external payload or processor ConfigMap does not exist.
+	ReasonMissingExternalConfig = "MissingExternalConfig"
+	// ReasonMissingEnvConfig means a Secret or ConfigMap referenced by a worker env var does not exist.
+	ReasonMissingEnvConfig = "MissingEnvConfig"
+	// ReasonInvalidTestScript means the test script could not be parsed.
+	ReasonInvalidTestScript = "InvalidTestScript"
+	// ReasonEnvironmentNotFound means the LoadTest's environment is not defined in the test script.
+	ReasonEnvironmentNotFound = "EnvironmentNotFound"
+	// ReasonImagePullError means workers can never pull the worker image, e.g. because its name is invalid.
+	ReasonImagePullError = "ImagePullError"
+	// ReasonDeadlineExceeded means the LoadTest's workers ran longer than their active deadline.
+	ReasonDeadlineExceeded = "DeadlineExceeded"
+	// ReasonSuspended means the LoadTest's workers are suspended.
+	ReasonSuspended = "Suspended"
+	// ReasonInvalidThreshold means one or more of the LoadTest's thresholds could not be parsed.
+	ReasonInvalidThreshold = "InvalidThreshold"
+	// ReasonAborted means the LoadTest was stopped using spec.abort.
+	ReasonAborted = "Aborted"
+	// ReasonPendingTimeout means a worker pod stayed pending for longer than the operator's pending timeout.
+	ReasonPendingTimeout = "PendingTimeout"
+	// ReasonSnapshotConflict means the LoadTest's test script snapshot ConfigMap name is taken by another ConfigMap.
+	ReasonSnapshotConflict = "SnapshotConflict"
+	// ReasonSnapshotTooLarge means the LoadTest's test script snapshot exceeds the ConfigMap size limit.
+	ReasonSnapshotTooLarge = "SnapshotTooLarge"
+)
+
+// Reasons used by the SLOMet condition to explain the outcome of evaluating a LoadTest's thresholds.
+const (
+	// ReasonSLOMet means the LoadTest met all its thresholds.
+	ReasonSLOMet = "SLOMet"
+	// ReasonSLOViolated means the LoadTest violated one or more of its thresholds.
+	ReasonSLOViolated = "SLOViolated"
+	// ReasonReportUnavailable means no worker test reports were available to evaluate the LoadTest's thresholds.
+	ReasonReportUnavailable = "ReportUnavailable"
 )
 
 // updateStatus updates the LoadTestStatus based on the status of created LoadTest objects.
@@ -67,10 +113,63 @@ func (r *LoadTestReconciler) updateStatus(ctx context.Context, v *lt.LoadTest, l
 		return &ctrl.Result{}, err
 	}
 
+	if workersFailedToStart(v) && !workersRunning(v) {
+		// Stop workers that may still start, the LoadTest has already failed.
+		// Running workers are left to finish, the Job is deleted once none are running.
+		logger.Info("Deleting Job of failed LoadTest", "Job.Namespace", found.Namespace, "Job.Name", found.Name)
+		if err := r.Delete(ctx, found, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+			logger.Error(err, "Failed to delete Job", "Job.Namespace", found.Namespace, "Job.Name", found.Name)
+			return &ctrl.Result{}, err
+		}
+	}
+
 	// status updated successfully
 	return nil, nil
 }
 
+// rejectLoadTest marks a LoadTest as Failed for the provided reason,
+// and publishes a matching Warning event.
+// Workers are not created for a rejected LoadTest, and it is not requeued
+// until its spec or test script are updated.
+func (r *LoadTestReconciler) rejectLoadTest(
+	ctx context.Context,
+	v *lt.LoadTest,
+	logger logr.Logger,
+	reason string,
+	message string,
+) (*reconcile.Result, error) {
+	logger.Info("Rejecting LoadTest", "Reason", reason, "Message", message)
+	r.Recorder.Event(v, "Warning", reason, message)
+
+	if err := r.failLoadTest(ctx, v, reason, message); err != nil {
+		logger.Error(err, "Failed to update LoadTest status")
+		return &ctrl.Result{}, err
+	}
+
+	return &ctrl.Result{}, nil
+}
+
+// failLoadTest updates a LoadTest's status to the Failed phase with a Failed condition
+// explaining the provided reason.
+// The status is left untouched if the LoadTest has already finished,
+// keeping its terminal phase, or if it has already failed for the same reason.
+func (r *LoadTestReconciler) failLoadTest(ctx context.Context, v *lt.LoadTest, reason string, message string) error {
+	if v.Status.CompletionTime != nil {
+		return nil
+	}
+
+	failed, ok := conditionsMap(v.Status.Conditions)[lt.LoadTestFailed]
+	if ok && failed.Status == corev1.ConditionTrue && failed.Reason == reason && failed.Message == message {
+		return nil
+	}
+
+	setCondition(v, lt.LoadTestProgressing, corev1.ConditionFalse, reason, message)
+	setCondition(v, lt.LoadTestFailed, corev1.ConditionTrue, reason, message)
+	v.Status.Phase = lt.LoadTestPhaseFailed
+
+	return r.Status().Update(ctx, v)
+}
+
 // configureStatus configures, and if need be, broadcasts the LoadTest status
 // based on observed status of the created Job object.
 func configureStatus(
@@ -83,7 +182,36 @@ func configureStatus(
 	observedStatus := observedStatus(job.Status)
 	configureStatesAndPrinterColumns(v, job)
 
-	setConditions(v, observedStatus)
+	podList, err := getPods(ctx, v, r.Client)
+	if err != nil {
+		return err
+	}
+	configureWorkers(v, podList)
+
+	progressing := conditionsMap(v.Status.Conditions)[lt.LoadTestProgressing]
+	switch {
+	case workersFailedToStart(v):
+		// Failed in a previous reconcile, its Job is being deleted
+		observedStatus = LoadTestFailed
+	default:
+		setConditions(v, observedStatus, job.Status)
+		if observedStatus == LoadTestInactive || observedStatus == LoadTestActive {
+			if configurePodFailures(v, r, podList, progressing, time.Now()) {
+				observedStatus = LoadTestFailed
+			}
+		}
+	}
+	configurePhase(v, observedStatus)
+
+	if (observedStatus == LoadTestCompleted || observedStatus == LoadTestFailed) && v.Status.Report == nil {
+		if err := r.configureReport(ctx, v, logger); err != nil {
+			return err
+		}
+	}
+	if observedStatus == LoadTestCompleted || observedStatus == LoadTestFailed {
+		configureSLO(v, r)
+	}
+
 	if err := broadcastIfActiveOrCompleted(ctx, v, r, observedStatus, logger); err != nil {
 		return err
 	}
@@ -94,71 +222,126 @@ func configureStatus(
 }
 
 // observedStatus relays a load test's observed status from its related Job.
+// Terminal Job conditions take precedence, followed by suspension,
+// a Job is otherwise active once started.
 func observedStatus(s v1.JobStatus) ObservedStatus {
+	suspended, complete, failed := jobConditions(s)
 	switch {
-	case jobConditionsActive(s) && (s.Active > (s.Succeeded + s.Failed)):
-		return LoadTestActive
-	case jobConditionsCompleted(s) && (s.Active == 0 && (s.Succeeded > 0 || s.Failed > 0)):
+	case failed:
+		return LoadTestFailed
+	case complete:
 		return LoadTestCompleted
+	case suspended:
+		return LoadTestSuspended
+	case s.StartTime != nil:
+		return LoadTestActive
 	default:
 		return LoadTestInactive
 	}
 }
 
-// jobConditionsActive use Job conditions to conclude if a job is active.
-func jobConditionsActive(s v1.JobStatus) bool {
-	suspended, complete, failed := jobConditions(s)
-	running := suspended == false && complete == false && failed == false
-	return s.StartTime != nil && running
-}
-
-// jobConditionsCompleted use Job conditions to conclude if a job has completed.
-func jobConditionsCompleted(s v1.JobStatus) bool {
-	suspended, complete, failed := jobConditions(s)
-	completed := suspended == false && complete == true && failed == false
-	return s.StartTime != nil && s.CompletionTime != nil && completed
-}
-
 // jobConditions returns whether a Job is suspended, complete or failed by traversing all found Conditions.
+// Only Conditions with a True status are taken into account.
 func jobConditions(s v1.JobStatus) (suspended bool, complete bool, failed bool) {
 	for _, c := range s.Conditions {
-		suspended = c.Type == v1.JobSuspended
-		complete = c.Type == v1.JobComplete
-		failed = c.Type == v1.JobFailed
+		if c.Status != corev1.ConditionTrue {
+			continue
+		}
+
+		switch c.Type {
+		case v1.JobSuspended:
+			suspended = true
+		case v1.JobComplete:
+			complete = true
+		case v1.JobFailed:
+			failed = true
+		}
 	}
 	return
 }
 
-// setConditions sets the LoadTest's Status Conditions based on
-// the provided observed state.
-func setConditions(v *lt.LoadTest, o ObservedStatus) {
-	var progressing lt.LoadTestCondition
-	var completed lt.LoadTestCondition
+// jobFailure returns the reason and message explaining why a Job failed.
+// A Job fails when it exceeds its active deadline, otherwise when its workers fail (BackoffLimitExceeded).
+func jobFailure(s v1.JobStatus) (reason string, message string) {
+	for _, c := range s.Conditions {
+		if c.Type != v1.JobFailed || c.Status != corev1.ConditionTrue {
+			continue
+		}
 
-	conditionsMap := conditionsMap(v.Status.Conditions)
-	progressing = conditionsMap[lt.LoadTestProgressing]
+		if c.Reason == "DeadlineExceeded" {
+			return ReasonDeadlineExceeded, fmt.Sprintf("Load Test workers exceeded their active deadline: %s", c.Message)
+		}
+	}
+
+	return ReasonWorkerFailed, fmt.Sprintf("%d of %d Load Test workers failed", s.Failed, s.Failed+s.Succeeded+s.Active)
+}
 
+// setConditions sets the LoadTest's Status Conditions based on
+// the provided observed state and the status of its related Job.
+// A LoadTest is only Completed if all its workers succeeded, otherwise it has Failed.
+func setConditions(v *lt.LoadTest, o ObservedStatus, s v1.JobStatus) {
 	switch o {
 	case LoadTestInactive:
-		progressing.Status = corev1.ConditionUnknown
+		setCondition(v, lt.LoadTestProgressing, corev1.ConditionUnknown, "", "")
+		removeCondition(v, lt.LoadTestFailed)
 
 	case LoadTestActive:
-		progressing.Status = corev1.ConditionTrue
+		setCondition(v, lt.LoadTestProgressing, corev1.ConditionTrue, "", "")
+		removeCondition(v, lt.LoadTestFailed)
+
+	case LoadTestSuspended:
+		setCondition(v, lt.LoadTestProgressing, corev1.ConditionFalse, ReasonSuspended, "Load Test workers are suspended")
+		removeCondition(v, lt.LoadTestFailed)
 
 	case LoadTestCompleted:
-		progressing.Status = corev1.ConditionFalse
+		setCondition(v, lt.LoadTestProgressing, corev1.ConditionFalse, "", "")
+		setCondition(v, lt.LoadTestCompleted, corev1.ConditionTrue, "", "")
 
-		completed = lt.LoadTestCondition{
-			Type:               lt.LoadTestCompleted,
-			Status:             corev1.ConditionTrue,
-			LastTransitionTime: metav1.Now(),
-			LastProbeTime:      metav1.Now(),
-		}
-		conditionsMap[lt.LoadTestCompleted] = completed
+	case LoadTestFailed:
+		reason, msg := jobFailure(s)
+		setCondition(v, lt.LoadTestProgressing, corev1.ConditionFalse, reason, msg)
+		setCondition(v, lt.LoadTestFailed, corev1.ConditionTrue, reason, msg)
 	}
+}
 
-	progressing.LastProbeTime = metav1.Now()
-	conditionsMap[lt.LoadTestProgressing] = progressing
+// configurePhase configures the LoadTest's phase based on its conditions
+// and the provided observed state.
+func configurePhase(v *lt.LoadTest, o ObservedStatus) {
+	failed, ok := conditionsMap(v.Status.Conditions)[lt.LoadTestFailed]
+
+	switch {
+	case ok && failed.Status == corev1.ConditionTrue:
+		v.Status.Phase = lt.LoadTestPhaseFailed
+	case o == LoadTestActive:
+		v.Status.Phase = lt.LoadTestPhaseRunning
+	case o == LoadTestCompleted:
+		v.Status.Phase = lt.LoadTestPhaseSucceeded
+	default:
+		v.Status.Phase = lt.LoadTestPhasePending
+	}
+}
+
+// setCondition sets a LoadTest Status Condition of the provided type.
+// The condition's transition time is only updated when its status changes.
+func setCondition(
+	v *lt.LoadTest,
+	t lt.LoadTestConditionType,
+	status corev1.ConditionStatus,
+	reason string,
+	message string,
+) {
+	conditionsMap := conditionsMap(v.Status.Conditions)
+
+	condition, ok := conditionsMap[t]
+	if !ok || condition.Status != status {
+		condition.LastTransitionTime = metav1.Now()
+	}
+	condition.Type = t
+	condition.Status = status
+	condition.Reason = reason
+	condition.Message = message
+	condition.LastProbeTime = metav1.Now()
+	conditionsMap[t] = condition
 
 	v.Status.Conditions = funk.Map(conditionsMap, func(key lt.LoadTestConditionType, val lt.LoadTestCondition) lt.LoadTestCondition {
 		return val
@@ -186,6 +369,70 @@ func configurePrinterColumns(v *lt.LoadTest, job *v1.Job) {
 	v.Status.Image = job.Spec.Template.Spec.Containers[0].Image
 }
 
+// configureWorkers lists the observed state of every worker pod, ordered by worker index.
+// Workers are kept once their pods are deleted, e.g. after the load test has finished.
+func configureWorkers(v *lt.LoadTest, podList *corev1.PodList) {
+	if len(podList.Items) == 0 && v.Status.CompletionTime != nil {
+		return
+	}
+
+	workers := make([]lt.LoadTestWorker, 0, len(podList.Items))
+	for _, pod := range podList.Items {
+		workers = append(workers, workerStatus(v, pod))
+	}
+
+	sort.SliceStable(workers, func(i, j int) bool {
+		a, b := workers[i], workers[j]
+		if a.Index != nil && b.Index != nil && *a.Index != *b.Index {
+			return *a.Index < *b.Index
+		}
+		return a.Name < b.Name
+	})
+
+	v.Status.Workers = workers
+}
+
+// workerStatus relays the observed state of a worker pod and its Artillery container.
+func workerStatus(v *lt.LoadTest, pod corev1.Pod) lt.LoadTestWorker {
+	worker := lt.LoadTestWorker{
+		Name:      pod.Name,
+		Node:      pod.Spec.NodeName,
+		Phase:     pod.Status.Phase,
+		StartTime: pod.Status.StartTime,
+	}
+
+	if index, err := strconv.ParseInt(pod.Annotations[v1.JobCompletionIndexAnnotation], 10, 32); err == nil {
+		i := int32(index)
+		worker.Index = &i
+	}
+
+	for _, cs := range pod.Status.ContainerStatuses {
+		if cs.Name != v.Name {
+			continue
+		}
+
+		worker.RestartCount = cs.RestartCount
+		switch {
+		case cs.State.Terminated != nil:
+			exitCode, finishTime := cs.State.Terminated.ExitCode, cs.State.Terminated.FinishedAt
+			worker.ExitCode = &exitCode
+			worker.FinishTime = &finishTime
+			worker.Reason = cs.State.Terminated.Reason
+		case cs.State.Waiting != nil:
+			worker.Reason = cs.State.Waiting.Reason
+		}
+	}
+
+	return worker
+}
+
+// removeCondition removes a LoadTest Status Condition of the provided type, if it exists.
+func removeCondition(v *lt.LoadTest, t lt.LoadTestConditionType) {
+	v.Status.Conditions = funk.Filter(v.Status.Conditions, func(c lt.LoadTestCondition) bool {
+		return c.Type != t
+	}).([]lt.LoadTestCondition)
+}
+
 func conditionsMap(conditions []lt.LoadTestCondition) map[lt.LoadTestConditionType]lt.LoadTestCondition {
 	out := funk.ToMap(conditions, "Type").(map[lt.LoadTestConditionType]lt.LoadTestCondition)
 	if _, ok := out[lt.LoadTestProgressing]; !ok {
@@ -207,7 +454,7 @@ func configureStartupAndCompletion(v *lt.LoadTest, o ObservedStatus) {
 			v.Status.StartTime = &now
 		}
 
-	case LoadTestCompleted:
+	case LoadTestCompleted, LoadTestFailed:
 		if v.Status.CompletionTime == nil {
 			now := metav1.Now()
 			v.Status.CompletionTime = &now
@@ -215,7 +462,7 @@ func configureStartupAndCompletion(v *lt.LoadTest, o ObservedStatus) {
 	}
 }
 
-// broadcastIfActiveOrCompleted broadcasts informational events to mark that a LoadTest has started or completed.
+// broadcastIfActiveOrCompleted broadcasts informational events to mark that a LoadTest has started, completed or failed.
 func broadcastIfActiveOrCompleted(ctx context.Context, v *lt.LoadTest, r *LoadTestReconciler, o ObservedStatus, logger logr.Logger) error {
 	switch {
 	case o == LoadTestActive && v.Status.StartTime == nil:
@@ -229,20 +476,123 @@ func broadcastIfActiveOrCompleted(ctx context.Context, v *lt.LoadTest, r *LoadTe
 		telemetry.TelemeterActive(v, r.TelemetryClient, r.TelemetryConfig, logger)
 
 	case o == LoadTestCompleted && v.Status.CompletionTime == nil:
-		msg := "Load Test completed"
-
-		if v.Status.Failed > 0 {
-			r.Recorder.Event(v, "Warning", "Failed", fmt.Sprintf("%s with failed workers", msg))
-		} else {
-			r.Recorder.Event(v, "Normal", "Completed", msg)
-		}
+		r.Recorder.Event(v, "Normal", "Completed", "Load Test completed")
+		telemetry.TelemeterCompletion(v, r.TelemetryClient, r.TelemetryConfig, logger)
 
+	case o == LoadTestFailed && v.Status.CompletionTime == nil:
+		failed := conditionsMap(v.Status.Conditions)[lt.LoadTestFailed]
+		r.Recorder.Eventf(v, "Warning", "Failed", "Load Test failed: %s", failed.Message)
 		telemetry.TelemeterCompletion(v, r.TelemetryClient, r.TelemetryConfig, logger)
 	}
 
 	return nil
 }
 
+// configurePodFailures surfaces worker pods unable to start, e.g. unschedulable or unable to pull their image.
+// The underlying reason is set on the Progressing condition, and published as a Warning event when it changes.
+// The LoadTest fails once a worker pod has been pending for longer than the operator's pending timeout,
+// e.g. while kubelet retries pulling its image, or straight away when its image can never be pulled.
+// It returns true if the LoadTest failed, its Job is then deleted once no workers are running.
+func configurePodFailures(v *lt.LoadTest, r *LoadTestReconciler, podList *corev1.PodList, progressing lt.LoadTestCondition, now time.Time) bool {
+	reason, msg, ok := podFailure(podList)
+	if ok {
+		setCondition(v, lt.LoadTestProgressing, corev1.ConditionUnknown, reason, msg)
+		if progressing.Reason != reason || progressing.Message != msg {
+			r.Recorder.Event(v, "Warning", reason, msg)
+		}
+	}
+
+	if msg, ok := imagePullFailure(podList); ok {
+		setCondition(v, lt.LoadTestFailed, corev1.ConditionTrue, ReasonImagePullError, msg)
+		return true
+	}
+
+	if msg, ok := pendingTimeout(podList, r.PendingTimeout, now); ok {
+		setCondition(v, lt.LoadTestFailed, corev1.ConditionTrue, ReasonPendingTimeout, msg)
+		return true
+	}
+
+	return false
+}
+
+// workersFailedToStart returns whether a LoadTest failed because its workers could not start,
+// a failure set by configurePodFailures that the status of its Job does not reflect.
+func workersFailedToStart(v *lt.LoadTest) bool {
+	failed, ok := conditionsMap(v.Status.Conditions)[lt.LoadTestFailed]
+	return v.Status.CompletionTime != nil && ok && failed.Status == corev1.ConditionTrue &&
+		(failed.Reason == ReasonImagePullError || failed.Reason == ReasonPendingTimeout)
+}
+
+// workersRunning returns whether any of the LoadTest's workers is running.
+func workersRunning(v *lt.LoadTest) bool {
+	for _, w := range v.Status.Workers {
+		if w.Phase == corev1.PodRunning {
+			return true
+		}
+	}
+	return false
+}
+
+// podFailure returns the reason and message explaining why the first pending worker Pod found cannot start.
+func podFailure(podList *corev1.PodList) (string, string, bool) {
+	for _, pod := range podList.Items {
+		if pod.Status.Phase != corev1.PodPending {
+			continue
+		}
+
+		for _, c := range pod.Status.Conditions {
+			if c.Type == corev1.PodScheduled && c.Status == corev1.ConditionFalse && c.Reason == corev1.PodReasonUnschedulable {
+				return c.Reason, fmt.Sprintf("Load Test worker pod %s cannot be scheduled: %s", pod.Name, c.Message), true
+			}
+		}
+
+		for _, cs := range pod.Status.ContainerStatuses {
+			if cs.State.Waiting == nil {
+				continue
+			}
+			switch reason := cs.State.Waiting.Reason; reason {
+			case "CreateContainerConfigError", "CreateContainerError", "RunContainerError",
+				"ErrImagePull", "ImagePullBackOff", "InvalidImageName", "ErrImageNeverPull":
+				return reason, fmt.Sprintf("Load Test worker pod %s cannot start: %s", pod.Name, cs.State.Waiting.Message), true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// imagePullFailure returns a message describing the first worker Pod found never able to pull its image.
+// Other image pull errors, e.g. ErrImagePull or ImagePullBackOff, may be temporary as kubelet retries pulling the image,
+// they fail the LoadTest once the worker Pod reaches the pending timeout.
+func imagePullFailure(podList *corev1.PodList) (string, bool) {
+	for _, pod := range podList.Items {
+		for _, cs := range pod.Status.ContainerStatuses {
+			if cs.State.Waiting == nil {
+				continue
+			}
+			switch cs.State.Waiting.Reason {
+			case "InvalidImageName", "ErrImageNeverPull":
+				return fmt.Sprintf("Load Test worker pod %s cannot pull image %s: %s", pod.Name, cs.Image, cs.State.Waiting.Message), true
+			}
+		}
+	}
+	return "", false
+}
+
+// pendingTimeout returns a message describing the first worker Pod found pending for longer than the provided timeout.
+// A zero timeout disables the check.
+func pendingTimeout(podList *corev1.PodList, timeout time.Duration, now time.Time) (string, bool) {
+	if timeout <= 0 {
+		return "", false
+	}
+
+	for _, pod := range podList.Items {
+		if pod.Status.Phase == corev1.PodPending && now.Sub(pod.CreationTimestamp.Time) > timeout {
+			return fmt.Sprintf("Load Test worker pod %s has been pending for more than %s", pod.Name, timeout), true
+		}
+	}
+	return "", false
+}
+
 // getPods returns all the worker Pods for a given LoadTest.
 func getPods(ctx context.Context, v *lt.LoadTest, ctl client.Client) (*corev1.PodList, error) {
 	podList := &corev1.PodList{}
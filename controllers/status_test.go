@@ -0,0 +1,656 @@
+/*
+ * Copyright (c) 2022.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0.
+ *
+ * If a copy of the MPL was not distributed with
+ * this file, You can obtain one at
+ *
+ *   http://mozilla.org/MPL/2.0/
+ */
+
+package controllers
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	lt "github.com/artilleryio/artillery-operator/api/v1alpha1"
+	"github.com/artilleryio/artillery-operator/internal/telemetry"
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/batch/v1"
+	corev1 "k8s.io/api/core/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func jobCondition(t v1.JobConditionType, s corev1.ConditionStatus, reason string) v1.JobCondition {
+	return v1.JobCondition{Type: t, Status: s, Reason: reason}
+}
+
+func TestJobConditions(t *testing.T) {
+	tests := []struct {
+		name          string
+		conditions    []v1.JobCondition
+		wantSuspended bool
+		wantComplete  bool
+		wantFailed    bool
+	}{
+		{
+			name: "no conditions",
+		},
+		{
+			name:         "complete",
+			conditions:   []v1.JobCondition{jobCondition(v1.JobComplete, corev1.ConditionTrue, "")},
+			wantComplete: true,
+		},
+		{
+			name:       "complete with a false status is ignored",
+			conditions: []v1.JobCondition{jobCondition(v1.JobComplete, corev1.ConditionFalse, "")},
+		},
+		{
+			name: "failed is not overwritten by later conditions",
+			conditions: []v1.JobCondition{
+				jobCondition(v1.JobFailed, corev1.ConditionTrue, "BackoffLimitExceeded"),
+				jobCondition(v1.JobSuspended, corev1.ConditionFalse, "JobResumed"),
+			},
+			wantFailed: true,
+		},
+		{
+			name: "resumed then completed",
+			conditions: []v1.JobCondition{
+				jobCondition(v1.JobSuspended, corev1.ConditionFalse, "JobResumed"),
+				jobCondition(v1.JobComplete, corev1.ConditionTrue, ""),
+			},
+			wantComplete: true,
+		},
+		{
+			name:          "suspended",
+			conditions:    []v1.JobCondition{jobCondition(v1.JobSuspended, corev1.ConditionTrue, "JobSuspended")},
+			wantSuspended: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			suspended, complete, failed := jobConditions(v1.JobStatus{Conditions: tt.conditions})
+			if suspended != tt.wantSuspended || complete != tt.wantComplete || failed != tt.wantFailed {
+				t.Errorf("jobConditions() = (suspended %v, complete %v, failed %v), want (%v, %v, %v)",
+					suspended, complete, failed, tt.wantSuspended, tt.wantComplete, tt.wantFailed)
+			}
+		})
+	}
+}
+
+func TestObservedStatus(t *testing.T) {
+	started := metav1.Now()
+
+	tests := []struct {
+		name   string
+		status v1.JobStatus
+		want   ObservedStatus
+	}{
+		{
+			name:   "not started",
+			status: v1.JobStatus{},
+			want:   LoadTestInactive,
+		},
+		{
+			name:   "started with active workers",
+			status: v1.JobStatus{StartTime: &started, Active: 4},
+			want:   LoadTestActive,
+		},
+		{
+			name:   "started with some workers succeeded",
+			status: v1.JobStatus{StartTime: &started, Active: 2, Succeeded: 2},
+			want:   LoadTestActive,
+		},
+		{
+			name:   "started with all workers succeeded but not yet complete",
+			status: v1.JobStatus{StartTime: &started, Succeeded: 4},
+			want:   LoadTestActive,
+		},
+		{
+			name: "complete with a false status",
+			status: v1.JobStatus{
+				StartTime:  &started,
+				Active:     1,
+				Conditions: []v1.JobCondition{jobCondition(v1.JobComplete, corev1.ConditionFalse, "")},
+			},
+			want: LoadTestActive,
+		},
+		{
+			name: "complete",
+			status: v1.JobStatus{
+				StartTime:      &started,
+				CompletionTime: &started,
+				Succeeded:      4,
+				Conditions:     []v1.JobCondition{jobCondition(v1.JobComplete, corev1.ConditionTrue, "")},
+			},
+			want: LoadTestCompleted,
+		},
+		{
+			name: "failed workers exceeded the backoff limit",
+			status: v1.JobStatus{
+				StartTime:  &started,
+				Succeeded:  3,
+				Failed:     1,
+				Conditions: []v1.JobCondition{jobCondition(v1.JobFailed, corev1.ConditionTrue, "BackoffLimitExceeded")},
+			},
+			want: LoadTestFailed,
+		},
+		{
+			name: "deadline exceeded",
+			status: v1.JobStatus{
+				StartTime:  &started,
+				Failed:     4,
+				Conditions: []v1.JobCondition{jobCondition(v1.JobFailed, corev1.ConditionTrue, "DeadlineExceeded")},
+			},
+			want: LoadTestFailed,
+		},
+		{
+			name: "suspended",
+			status: v1.JobStatus{
+				Conditions: []v1.JobCondition{jobCondition(v1.JobSuspended, corev1.ConditionTrue, "JobSuspended")},
+			},
+			want: LoadTestSuspended,
+		},
+		{
+			name: "resumed",
+			status: v1.JobStatus{
+				StartTime:  &started,
+				Active:     4,
+				Conditions: []v1.JobCondition{jobCondition(v1.JobSuspended, corev1.ConditionFalse, "JobResumed")},
+			},
+			want: LoadTestActive,
+		},
+		{
+			name: "suspended then failed",
+			status: v1.JobStatus{
+				Conditions: []v1.JobCondition{
+					jobCondition(v1.JobSuspended, corev1.ConditionTrue, "JobSuspended"),
+					jobCondition(v1.JobFailed, corev1.ConditionTrue, "DeadlineExceeded"),
+				},
+			},
+			want: LoadTestFailed,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := observedStatus(tt.status); got != tt.want {
+				t.Errorf("observedStatus() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSetConditionsAndPhase(t *testing.T) {
+	started := metav1.Now()
+
+	tests := []struct {
+		name        string
+		status      v1.JobStatus
+		wantPhase   lt.LoadTestPhase
+		wantTypes   map[lt.LoadTestConditionType]corev1.ConditionStatus
+		wantReason  string
+		absentTypes []lt.LoadTestConditionType
+	}{
+		{
+			name:      "inactive",
+			status:    v1.JobStatus{},
+			wantPhase: lt.LoadTestPhasePending,
+			wantTypes: map[lt.LoadTestConditionType]corev1.ConditionStatus{
+				lt.LoadTestProgressing: corev1.ConditionUnknown,
+			},
+			absentTypes: []lt.LoadTestConditionType{lt.LoadTestCompleted, lt.LoadTestFailed},
+		},
+		{
+			name:      "active",
+			status:    v1.JobStatus{StartTime: &started, Active: 2},
+			wantPhase: lt.LoadTestPhaseRunning,
+			wantTypes: map[lt.LoadTestConditionType]corev1.ConditionStatus{
+				lt.LoadTestProgressing: corev1.ConditionTrue,
+			},
+			absentTypes: []lt.LoadTestConditionType{lt.LoadTestCompleted, lt.LoadTestFailed},
+		},
+		{
+			name: "suspended",
+			status: v1.JobStatus{
+				Conditions: []v1.JobCondition{jobCondition(v1.JobSuspended, corev1.ConditionTrue, "JobSuspended")},
+			},
+			wantPhase: lt.LoadTestPhasePending,
+			wantTypes: map[lt.LoadTestConditionType]corev1.ConditionStatus{
+				lt.LoadTestProgressing: corev1.ConditionFalse,
+			},
+			wantReason:  ReasonSuspended,
+			absentTypes: []lt.LoadTestConditionType{lt.LoadTestCompleted, lt.LoadTestFailed},
+		},
+		{
+			name: "completed",
+			status: v1.JobStatus{
+				StartTime:  &started,
+				Succeeded:  2,
+				Conditions: []v1.JobCondition{jobCondition(v1.JobComplete, corev1.ConditionTrue, "")},
+			},
+			wantPhase: lt.LoadTestPhaseSucceeded,
+			wantTypes: map[lt.LoadTestConditionType]corev1.ConditionStatus{
+				lt.LoadTestProgressing: corev1.ConditionFalse,
+				lt.LoadTestCompleted:   corev1.ConditionTrue,
+			},
+			absentTypes: []lt.LoadTestConditionType{lt.LoadTestFailed},
+		},
+		{
+			name: "workers failed",
+			status: v1.JobStatus{
+				StartTime:  &started,
+				Succeeded:  1,
+				Failed:     1,
+				Conditions: []v1.JobCondition{jobCondition(v1.JobFailed, corev1.ConditionTrue, "BackoffLimitExceeded")},
+			},
+			wantPhase: lt.LoadTestPhaseFailed,
+			wantTypes: map[lt.LoadTestConditionType]corev1.ConditionStatus{
+				lt.LoadTestProgressing: corev1.ConditionFalse,
+				lt.LoadTestFailed:      corev1.ConditionTrue,
+			},
+			wantReason:  ReasonWorkerFailed,
+			absentTypes: []lt.LoadTestConditionType{lt.LoadTestCompleted},
+		},
+		{
+			name: "deadline exceeded",
+			status: v1.JobStatus{
+				StartTime:  &started,
+				Failed:     2,
+				Conditions: []v1.JobCondition{jobCondition(v1.JobFailed, corev1.ConditionTrue, "DeadlineExceeded")},
+			},
+			wantPhase: lt.LoadTestPhaseFailed,
+			wantTypes: map[lt.LoadTestConditionType]corev1.ConditionStatus{
+				lt.LoadTestProgressing: corev1.ConditionFalse,
+				lt.LoadTestFailed:      corev1.ConditionTrue,
+			},
+			wantReason:  ReasonDeadlineExceeded,
+			absentTypes: []lt.LoadTestConditionType{lt.LoadTestCompleted},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &lt.LoadTest{}
+			o := observedStatus(tt.status)
+
+			setConditions(v, o, tt.status)
+			configurePhase(v, o)
+
+			if v.Status.Phase != tt.wantPhase {
+				t.Errorf("Phase = %v, want %v", v.Status.Phase, tt.wantPhase)
+			}
+
+			conditions := conditionsMap(v.Status.Conditions)
+			for conditionType, want := range tt.wantTypes {
+				if got := conditions[conditionType].Status; got != want {
+					t.Errorf("%s condition status = %v, want %v", conditionType, got, want)
+				}
+			}
+			for _, conditionType := range tt.absentTypes {
+				if _, ok := conditions[conditionType]; ok {
+					t.Errorf("%s condition should not be set", conditionType)
+				}
+			}
+			if got := conditions[lt.LoadTestProgressing].Reason; got != tt.wantReason {
+				t.Errorf("Progressing condition reason = %q, want %q", got, tt.wantReason)
+			}
+		})
+	}
+}
+
+func TestSetConditionsClearsFailure(t *testing.T) {
+	v := &lt.LoadTest{}
+	setCondition(v, lt.LoadTestFailed, corev1.ConditionTrue, ReasonMissingTestScript, "missing")
+
+	started := metav1.Now()
+	status := v1.JobStatus{StartTime: &started, Active: 1}
+	o := observedStatus(status)
+
+	setConditions(v, o, status)
+	configurePhase(v, o)
+
+	if _, ok := conditionsMap(v.Status.Conditions)[lt.LoadTestFailed]; ok {
+		t.Errorf("Failed condition should be cleared once workers are running")
+	}
+	if v.Status.Phase != lt.LoadTestPhaseRunning {
+		t.Errorf("Phase = %v, want %v", v.Status.Phase, lt.LoadTestPhaseRunning)
+	}
+}
+
+func workerPod(name string, index string, state corev1.ContainerState) corev1.Pod {
+	return corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:        name,
+			Annotations: map[string]string{v1.JobCompletionIndexAnnotation: index},
+		},
+		Spec: corev1.PodSpec{NodeName: "node-" + index},
+		Status: corev1.PodStatus{
+			Phase:             corev1.PodRunning,
+			ContainerStatuses: []corev1.ContainerStatus{{Name: "basic-test", State: state, RestartCount: 1}},
+		},
+	}
+}
+
+func TestConfigureWorkers(t *testing.T) {
+	v := &lt.LoadTest{ObjectMeta: metav1.ObjectMeta{Name: "basic-test"}}
+	finished := metav1.Now()
+
+	podList := &corev1.PodList{Items: []corev1.Pod{
+		workerPod("basic-test-1-b", "1", corev1.ContainerState{Waiting: &corev1.ContainerStateWaiting{Reason: "ContainerCreating"}}),
+		workerPod("basic-test-0-a", "0", corev1.ContainerState{
+			Terminated: &corev1.ContainerStateTerminated{ExitCode: 137, Reason: "OOMKilled", FinishedAt: finished},
+		}),
+	}}
+
+	configureWorkers(v, podList)
+
+	if len(v.Status.Workers) != 2 {
+		t.Fatalf("configureWorkers() = %d workers, want 2", len(v.Status.Workers))
+	}
+
+	first, second := v.Status.Workers[0], v.Status.Workers[1]
+	if first.Name != "basic-test-0-a" || *first.Index != 0 || first.Node != "node-0" {
+		t.Errorf("configureWorkers() first worker = %+v, want basic-test-0-a", first)
+	}
+	if first.ExitCode == nil || *first.ExitCode != 137 || first.Reason != "OOMKilled" || !first.FinishTime.Equal(&finished) {
+		t.Errorf("configureWorkers() first worker termination = %+v, want exit code 137, OOMKilled", first)
+	}
+	if first.RestartCount != 1 {
+		t.Errorf("configureWorkers() first worker restart count = %d, want 1", first.RestartCount)
+	}
+	if second.Name != "basic-test-1-b" || second.ExitCode != nil || second.Reason != "ContainerCreating" {
+		t.Errorf("configureWorkers() second worker = %+v, want basic-test-1-b waiting", second)
+	}
+
+	// Workers are kept once a finished load test's pods are deleted
+	v.Status.CompletionTime = &finished
+	configureWorkers(v, &corev1.PodList{})
+	if len(v.Status.Workers) != 2 {
+		t.Errorf("configureWorkers() = %d workers once pods are deleted, want 2", len(v.Status.Workers))
+	}
+}
+
+func pendingPod(name string, created time.Time, conditions []corev1.PodCondition, waiting *corev1.ContainerStateWaiting) corev1.Pod {
+	return corev1.Pod{
+		ObjectMeta: metav1.ObjectMeta{Name: name, CreationTimestamp: metav1.NewTime(created)},
+		Status: corev1.PodStatus{
+			Phase:             corev1.PodPending,
+			Conditions:        conditions,
+			ContainerStatuses: []corev1.ContainerStatus{{State: corev1.ContainerState{Waiting: waiting}}},
+		},
+	}
+}
+
+func TestConfigurePodFailures(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	unschedulable := []corev1.PodCondition{{
+		Type:    corev1.PodScheduled,
+		Status:  corev1.ConditionFalse,
+		Reason:  corev1.PodReasonUnschedulable,
+		Message: "0/3 nodes are available: 3 Insufficient cpu.",
+	}}
+
+	tests := []struct {
+		name       string
+		pod        corev1.Pod
+		wantReason string
+		wantFailed string
+	}{
+		{
+			name:       "unschedulable",
+			pod:        pendingPod("worker", now.Add(-time.Minute), unschedulable, nil),
+			wantReason: "Unschedulable",
+		},
+		{
+			name:       "unschedulable past the pending timeout",
+			pod:        pendingPod("worker", now.Add(-10*time.Minute), unschedulable, nil),
+			wantReason: "Unschedulable",
+			wantFailed: ReasonPendingTimeout,
+		},
+		{
+			name:       "container config error",
+			pod:        pendingPod("worker", now, nil, &corev1.ContainerStateWaiting{Reason: "CreateContainerConfigError", Message: `secret "api" not found`}),
+			wantReason: "CreateContainerConfigError",
+		},
+		{
+			name:       "image pull error",
+			pod:        pendingPod("worker", now, nil, &corev1.ContainerStateWaiting{Reason: "ErrImagePull"}),
+			wantReason: "ErrImagePull",
+		},
+		{
+			name:       "image pull back off past the pending timeout",
+			pod:        pendingPod("worker", now.Add(-10*time.Minute), nil, &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff"}),
+			wantReason: "ImagePullBackOff",
+			wantFailed: ReasonPendingTimeout,
+		},
+		{
+			name:       "invalid image name",
+			pod:        pendingPod("worker", now, nil, &corev1.ContainerStateWaiting{Reason: "InvalidImageName"}),
+			wantReason: "InvalidImageName",
+			wantFailed: ReasonImagePullError,
+		},
+		{
+			name: "creating container",
+			pod:  pendingPod("worker", now, nil, &corev1.ContainerStateWaiting{Reason: "ContainerCreating"}),
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			r := &LoadTestReconciler{Recorder: recorder, PendingTimeout: 5 * time.Minute}
+			v := &lt.LoadTest{}
+			podList := &corev1.PodList{Items: []corev1.Pod{tt.pod}}
+
+			setCondition(v, lt.LoadTestProgressing, corev1.ConditionUnknown, "", "")
+			configurePodFailures(v, r, podList, lt.LoadTestCondition{}, now)
+
+			conditions := conditionsMap(v.Status.Conditions)
+			if got := conditions[lt.LoadTestProgressing].Reason; got != tt.wantReason {
+				t.Errorf("Progressing reason = %q, want %q", got, tt.wantReason)
+			}
+			if got := conditions[lt.LoadTestFailed].Reason; got != tt.wantFailed {
+				t.Errorf("Failed reason = %q, want %q", got, tt.wantFailed)
+			}
+
+			wantEvents := 0
+			if tt.wantReason != "" {
+				wantEvents = 1
+			}
+			if len(recorder.Events) != wantEvents {
+				t.Errorf("published %d events, want %d", len(recorder.Events), wantEvents)
+			}
+
+			// The event is only published once for the same failure
+			configurePodFailures(v, r, podList, conditions[lt.LoadTestProgressing], now)
+			if len(recorder.Events) != wantEvents {
+				t.Errorf("published %d events after reconciling again, want %d", len(recorder.Events), wantEvents)
+			}
+		})
+	}
+}
+
+func TestPendingTimeoutDisabled(t *testing.T) {
+	now := time.Now()
+	podList := &corev1.PodList{Items: []corev1.Pod{pendingPod("worker", now.Add(-time.Hour), nil, nil)}}
+
+	if _, ok := pendingTimeout(podList, 0, now); ok {
+		t.Errorf("pendingTimeout() timed out with a disabled timeout")
+	}
+}
+
+func TestFailLoadTest(t *testing.T) {
+	completed := metav1.Now()
+
+	tests := []struct {
+		name      string
+		status    lt.LoadTestStatus
+		wantPhase lt.LoadTestPhase
+	}{
+		{
+			name:      "pending",
+			status:    lt.LoadTestStatus{Phase: lt.LoadTestPhasePending},
+			wantPhase: lt.LoadTestPhaseFailed,
+		},
+		{
+			name:      "succeeded",
+			status:    lt.LoadTestStatus{Phase: lt.LoadTestPhaseSucceeded, CompletionTime: &completed},
+			wantPhase: lt.LoadTestPhaseSucceeded,
+		},
+		{
+			name:      "aborted",
+			status:    lt.LoadTestStatus{Phase: lt.LoadTestPhaseAborted, CompletionTime: &completed},
+			wantPhase: lt.LoadTestPhaseAborted,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &lt.LoadTest{
+				ObjectMeta: metav1.ObjectMeta{Name: "basic-test", Namespace: "default"},
+				Status:     tt.status,
+			}
+			r := &LoadTestReconciler{Client: fake.NewClientBuilder().WithScheme(testScheme()).WithObjects(v).Build()}
+
+			if err := r.failLoadTest(context.Background(), v, ReasonMissingExternalConfig, "missing"); err != nil {
+				t.Fatalf("failLoadTest() error = %v", err)
+			}
+
+			if v.Status.Phase != tt.wantPhase {
+				t.Errorf("failLoadTest() phase = %s, want %s", v.Status.Phase, tt.wantPhase)
+			}
+			_, failed := conditionsMap(v.Status.Conditions)[lt.LoadTestFailed]
+			if wantFailed := tt.wantPhase == lt.LoadTestPhaseFailed; failed != wantFailed {
+				t.Errorf("failLoadTest() Failed condition = %v, want %v", failed, wantFailed)
+			}
+		})
+	}
+}
+
+func TestPendingTimeoutFailsLoadTest(t *testing.T) {
+	ctx := context.Background()
+	v := &lt.LoadTest{
+		ObjectMeta: metav1.ObjectMeta{Name: "basic-test", Namespace: "default", UID: "uid"},
+		Spec:       lt.LoadTestSpec{Count: 1},
+	}
+
+	recorder := record.NewFakeRecorder(20)
+	r := &LoadTestReconciler{
+		Scheme:          testScheme(),
+		Recorder:        recorder,
+		TelemetryConfig: telemetry.Config{Debug: true},
+		PendingTimeout:  5 * time.Minute,
+	}
+
+	job := r.job(v)
+	started := metav1.NewTime(time.Now().Add(-10 * time.Minute))
+	job.Status = v1.JobStatus{StartTime: &started, Active: 1}
+	pod := pendingPod("basic-test-0-abcde", started.Time, nil, nil)
+	pod.Namespace, pod.Labels = v.Namespace, labels(v, "loadtest-worker")
+	r.Client = fake.NewClientBuilder().WithScheme(r.Scheme).WithObjects(v, job, &pod).Build()
+
+	if result, err := r.updateStatus(ctx, v, logr.Discard()); result != nil || err != nil {
+		t.Fatalf("updateStatus() = %v, %v, want nil, nil", result, err)
+	}
+
+	failed := conditionsMap(v.Status.Conditions)[lt.LoadTestFailed]
+	if v.Status.Phase != lt.LoadTestPhaseFailed || failed.Reason != ReasonPendingTimeout || v.Status.CompletionTime == nil {
+		t.Fatalf("updateStatus() phase = %s, Failed reason = %q, completion = %v, want a failed and finished LoadTest",
+			v.Status.Phase, failed.Reason, v.Status.CompletionTime)
+	}
+	if err := r.Get(ctx, types.NamespacedName{Name: job.Name, Namespace: job.Namespace}, &v1.Job{}); !errors.IsNotFound(err) {
+		t.Errorf("updateStatus() did not delete the Job of the failed LoadTest")
+	}
+
+	// The Job is observed again, e.g. from a stale cache, while its worker pod starts
+	pod.Status.Phase = corev1.PodRunning
+	if err := r.Update(ctx, &pod); err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 2; i++ {
+		if err := configureStatus(ctx, v, r, job, logr.Discard()); err != nil {
+			t.Fatalf("configureStatus() error = %v", err)
+		}
+	}
+
+	if v.Status.Phase != lt.LoadTestPhaseFailed {
+		t.Errorf("configureStatus() phase = %s, want %s", v.Status.Phase, lt.LoadTestPhaseFailed)
+	}
+	if got := conditionsMap(v.Status.Conditions)[lt.LoadTestFailed]; got != failed {
+		t.Errorf("configureStatus() Failed condition = %+v, want %+v", got, failed)
+	}
+
+	var failedEvents int
+	for len(recorder.Events) > 0 {
+		if strings.HasPrefix(<-recorder.Events, "Warning Failed") {
+			failedEvents++
+		}
+	}
+	if failedEvents != 1 {
+		t.Errorf("published %d Failed events, want 1", failedEvents)
+	}
+
+	// The Job of the failed LoadTest is not recreated
+	if result, err := r.ensureJob(ctx, v, logr.Discard(), r.job(v)); result == nil || err != nil {
+		t.Errorf("ensureJob() = %v, %v, want a result", result, err)
+	}
+}
+
+func TestPendingTimeoutKeepsRunningWorkers(t *testing.T) {
+	ctx := context.Background()
+	v := &lt.LoadTest{
+		ObjectMeta: metav1.ObjectMeta{Name: "basic-test", Namespace: "default", UID: "uid"},
+		Spec:       lt.LoadTestSpec{Count: 2},
+	}
+
+	r := &LoadTestReconciler{
+		Scheme:          testScheme(),
+		Recorder:        record.NewFakeRecorder(20),
+		TelemetryConfig: telemetry.Config{Debug: true},
+		PendingTimeout:  5 * time.Minute,
+	}
+
+	job := r.job(v)
+	started := metav1.NewTime(time.Now().Add(-10 * time.Minute))
+	job.Status = v1.JobStatus{StartTime: &started, Active: 2}
+	pending := pendingPod("basic-test-0-abcde", started.Time, nil, &corev1.ContainerStateWaiting{Reason: "ImagePullBackOff"})
+	running := pendingPod("basic-test-1-fghij", started.Time, nil, nil)
+	running.Status.Phase = corev1.PodRunning
+	for _, pod := range []*corev1.Pod{&pending, &running} {
+		pod.Namespace, pod.Labels = v.Namespace, labels(v, "loadtest-worker")
+	}
+	r.Client = fake.NewClientBuilder().WithScheme(r.Scheme).WithObjects(v, job, &pending, &running).Build()
+
+	if result, err := r.updateStatus(ctx, v, logr.Discard()); result != nil || err != nil {
+		t.Fatalf("updateStatus() = %v, %v, want nil, nil", result, err)
+	}
+	if failed := conditionsMap(v.Status.Conditions)[lt.LoadTestFailed]; failed.Reason != ReasonPendingTimeout {
+		t.Fatalf("updateStatus() Failed reason = %q, want %q", failed.Reason, ReasonPendingTimeout)
+	}
+	if err := r.Get(ctx, types.NamespacedName{Name: job.Name, Namespace: job.Namespace}, &v1.Job{}); err != nil {
+		t.Errorf("updateStatus() deleted the Job of a failed LoadTest with running workers: %v", err)
+	}
+
+	// Deleted once the running worker finishes
+	running.Status.Phase = corev1.PodSucceeded
+	if err := r.Update(ctx, &running); err != nil {
+		t.Fatal(err)
+	}
+	if result, err := r.updateStatus(ctx, v, logr.Discard()); result != nil || err != nil {
+		t.Fatalf("updateStatus() = %v, %v, want nil, nil", result, err)
+	}
+	if err := r.Get(ctx, types.NamespacedName{Name: job.Name, Namespace: job.Namespace}, &v1.Job{}); !errors.IsNotFound(err) {
+		t.Errorf("updateStatus() did not delete the Job once no workers are running")
+	}
+}
@@ -14,40 +14,532 @@ package controllers
 
 import (
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"sort"
+	"strings"
 
 	lt "github.com/artilleryio/artillery-operator/api/v1alpha1"
 	"github.com/go-logr/logr"
+	"github.com/thoas/go-funk"
+	"gopkg.in/yaml.v3"
 	core "k8s.io/api/core/v1"
 	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
 	"k8s.io/apimachinery/pkg/types"
 	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+	"sigs.k8s.io/controller-runtime/pkg/log"
+	"sigs.k8s.io/controller-runtime/pkg/reconcile"
 )
 
 // ensureTestScriptConfig ensures the test script ConfigMap defined
 // in the LoadTest Custom Resource is available on the cluster.
 // If not, a Warning event is triggered.
 // This event is viewable when running: kubectl describe loadtest <loadtest-name>.
+// Inline test scripts are stored in a ConfigMap generated and owned by the LoadTest.
 func (r *LoadTestReconciler) ensureTestScriptConfig(ctx context.Context,
 	instance *lt.LoadTest,
 	logger logr.Logger) (*ctrl.Result, error) {
-	configMap := instance.Spec.TestScript.Config.ConfigMap
+	if launched(instance) {
+		// Workers run the test script snapshot, test script updates no longer affect them
+		return nil, nil
+	}
+
+	testScript := instance.Spec.TestScript
+	switch {
+	case testScript.Inline != "" && testScript.Config.ConfigMap != "":
+		return r.rejectLoadTest(ctx, instance, logger, ReasonInvalidTestScript,
+			"Load Test test script must be set using either .spec.testScript.config.configMap or .spec.testScript.inline, not both")
+	case testScript.Inline != "":
+		if result, err := r.ensureInlineTestScript(ctx, instance, logger); result != nil {
+			return result, err
+		}
+	case testScript.Config.ConfigMap == "":
+		return r.rejectLoadTest(ctx, instance, logger, ReasonMissingTestScript,
+			"Load Test test script is missing, see fields .spec.testScript.config.configMap or .spec.testScript.inline")
+	}
+
+	configMap := testScriptConfigMapName(instance)
+
+	found, err := r.testScriptConfigMap(ctx, instance)
+	if err != nil && errors.IsNotFound(err) {
+		logger.Error(err, "TestScript ConfigMap is missing", "Testscript.Config.ConfigMap", configMap)
+		msg := "Load Test test script ConfigMap is missing, see field .spec.testScript.config.configMap"
+		r.Recorder.Event(instance, "Warning", ReasonMissingTestScript, msg)
+
+		if err := r.failLoadTest(ctx, instance, ReasonMissingTestScript, msg); err != nil {
+			logger.Error(err, "Failed to update LoadTest status")
+			return &ctrl.Result{}, err
+		}
+
+		// Test script ConfigMaps are watched, the LoadTest is requeued once it's created
+		return &ctrl.Result{}, nil
+	} else if err != nil {
+		logger.Error(err, "Failed to get ConfigMap", "Testscript.Config.ConfigMap", configMap)
+		return &ctrl.Result{}, err
+	}
+
+	// ConfigMap located, its test script must be valid before any workers are created.
+	var msg string
+	if script, err := parseTestScript(found, testScriptKey(instance)); err != nil {
+		msg = fmt.Sprintf("Load Test test script could not be parsed: %s", err)
+	} else if errs := script.validate(instance.Spec.Environment); len(errs) > 0 {
+		msg = fmt.Sprintf("Load Test test script is invalid: %s", scriptErrorsMessage(errs))
+	} else if err := validateWorkerScripts(instance, found); err != nil {
+		msg = fmt.Sprintf("Load Test test script is invalid: %s", err)
+	}
+
+	if msg != "" {
+		setCondition(instance, lt.LoadTestScriptInvalid, core.ConditionTrue, ReasonInvalidTestScript, msg)
+		return r.rejectLoadTest(ctx, instance, logger, ReasonInvalidTestScript, msg)
+	}
+
+	if _, ok := conditionsMap(instance.Status.Conditions)[lt.LoadTestScriptInvalid]; ok {
+		// The test script was fixed
+		removeCondition(instance, lt.LoadTestScriptInvalid)
+		if err := r.Status().Update(ctx, instance); err != nil {
+			logger.Error(err, "Failed to update LoadTest status")
+			return &ctrl.Result{}, err
+		}
+	}
+
+	// Persisted along with the LoadTest's status
+	instance.Status.TestScript = testScriptRevision(found, testScriptKey(instance))
+
+	return nil, nil
+}
+
+// testScriptRevision identifies the test script revision held by the test script ConfigMap's key.
+func testScriptRevision(cm *core.ConfigMap, key string) *lt.LoadTestScriptStatus {
+	sum := sha256.Sum256([]byte(cm.Data[key]))
+	return &lt.LoadTestScriptStatus{
+		ConfigMap:       cm.Name,
+		ResourceVersion: cm.ResourceVersion,
+		Hash:            "sha256:" + hex.EncodeToString(sum[:]),
+	}
+}
+
+// configMapRefsKey indexes LoadTests by the test script, external and env ConfigMaps they reference.
+const configMapRefsKey = ".spec.testScript.configMaps"
+
+// configMapRefs returns the names of the test script, external and env ConfigMaps referenced by a LoadTest.
+// Generated inline test script ConfigMaps are excluded, as they're owned by the LoadTest.
+func configMapRefs(obj client.Object) []string {
+	v, ok := obj.(*lt.LoadTest)
+	if !ok {
+		return nil
+	}
+
+	var out []string
+	if v.Spec.TestScript.Inline == "" && v.Spec.TestScript.Config.ConfigMap != "" {
+		out = append(out, v.Spec.TestScript.Config.ConfigMap)
+	}
+	for _, ref := range externalConfigRefs(v) {
+		out = append(out, ref.configMap)
+	}
+	for _, ref := range envRefs(v) {
+		if !ref.secret {
+			out = append(out, ref.name)
+		}
+	}
+	if len(out) == 0 {
+		return nil
+	}
+	return funk.UniqString(out)
+}
+
+// loadTestsForConfigMap requests the reconciliation of every LoadTest referencing the ConfigMap,
+// e.g. to start a LoadTest waiting for its test script to be created.
+func (r *LoadTestReconciler) loadTestsForConfigMap(obj client.Object) []reconcile.Request {
+	var loadTests lt.LoadTestList
+	if err := r.List(context.Background(), &loadTests,
+		client.InNamespace(obj.GetNamespace()),
+		client.MatchingFields{configMapRefsKey: obj.GetName()},
+	); err != nil {
+		log.Log.Error(err, "Failed to list LoadTests referencing ConfigMap", "ConfigMap.Namespace", obj.GetNamespace(), "ConfigMap.Name", obj.GetName())
+		return nil
+	}
+
+	requests := make([]reconcile.Request, len(loadTests.Items))
+	for i, v := range loadTests.Items {
+		requests[i] = reconcile.Request{NamespacedName: types.NamespacedName{Name: v.Name, Namespace: v.Namespace}}
+	}
+	return requests
+}
+
+// testScriptConfigMapName returns the name of the ConfigMap holding the LoadTest's test script.
+func testScriptConfigMapName(v *lt.LoadTest) string {
+	if v.Spec.TestScript.Inline != "" {
+		return v.Name + "-test-script"
+	}
+	return v.Spec.TestScript.Config.ConfigMap
+}
+
+// testScriptKey returns the key of the ConfigMap entry holding the LoadTest's test script.
+func testScriptKey(v *lt.LoadTest) string {
+	if v.Spec.TestScript.Inline == "" && v.Spec.TestScript.Config.Key != "" {
+		return v.Spec.TestScript.Config.Key
+	}
+	return TestScriptFilename
+}
+
+// inlineTestScriptConfigMap creates the ConfigMap holding a LoadTest's inline test script.
+func inlineTestScriptConfigMap(v *lt.LoadTest) *core.ConfigMap {
+	return &core.ConfigMap{
+		ObjectMeta: metav1.ObjectMeta{
+			Name:      testScriptConfigMapName(v),
+			Namespace: v.Namespace,
+			Labels:    labels(v, "test-script"),
+		},
+		Data: map[string]string{TestScriptFilename: v.Spec.TestScript.Inline},
+	}
+}
+
+// testScriptConfigMap returns the ConfigMap holding the LoadTest's test script.
+// Inline test scripts are returned as generated, as their ConfigMap may not be cached yet.
+func (r *LoadTestReconciler) testScriptConfigMap(ctx context.Context, v *lt.LoadTest) (*core.ConfigMap, error) {
+	if v.Spec.TestScript.Inline != "" {
+		return inlineTestScriptConfigMap(v), nil
+	}
 
 	found := &core.ConfigMap{}
 	err := r.Get(ctx, types.NamespacedName{
-		Name:      configMap,
-		Namespace: instance.Namespace,
+		Name:      v.Spec.TestScript.Config.ConfigMap,
+		Namespace: v.Namespace,
+	}, found)
+	return found, err
+}
+
+// ensureInlineTestScript creates the ConfigMap holding a LoadTest's inline test script,
+// or updates it when the inline test script changes.
+// The ConfigMap is owned by the LoadTest, and garbage collected along with it.
+func (r *LoadTestReconciler) ensureInlineTestScript(ctx context.Context,
+	instance *lt.LoadTest,
+	logger logr.Logger) (*ctrl.Result, error) {
+	configMap := inlineTestScriptConfigMap(instance)
+	if err := ctrl.SetControllerReference(instance, configMap, r.Scheme); err != nil {
+		return &ctrl.Result{}, err
+	}
+
+	found := &core.ConfigMap{}
+	err := r.Get(ctx, types.NamespacedName{
+		Name:      configMap.Name,
+		Namespace: configMap.Namespace,
 	}, found)
 
 	if err != nil && errors.IsNotFound(err) {
-		logger.Error(err, "TestScript ConfigMap is missing", "Testscript.Config.ConfigMap", configMap)
-		r.Recorder.Eventf(instance, "Warning", "MissingTestScript", "Load Test test script ConfigMap is missing, see field .spec.testScript.config.configMap")
+		logger.Info("Creating a new test script ConfigMap", "ConfigMap.Namespace", configMap.Namespace, "ConfigMap.Name", configMap.Name)
 
-		return &ctrl.Result{}, err
+		if err := r.Create(ctx, configMap); err != nil {
+			logger.Error(err, "Failed to create test script ConfigMap", "ConfigMap.Namespace", configMap.Namespace, "ConfigMap.Name", configMap.Name)
+			return &ctrl.Result{}, err
+		}
+
+		r.Recorder.Eventf(instance, "Normal", "Created", "Created Load Test test script ConfigMap: %s", configMap.Name)
+		return nil, nil
 	} else if err != nil {
-		logger.Error(err, "Failed to get ConfigMap", "Testscript.Config.ConfigMap", configMap)
+		logger.Error(err, "Failed to get ConfigMap", "ConfigMap.Namespace", configMap.Namespace, "ConfigMap.Name", configMap.Name)
 		return &ctrl.Result{}, err
 	}
 
-	// ConfigMap located
+	if !metav1.IsControlledBy(found, instance) {
+		return r.rejectLoadTest(ctx, instance, logger, ReasonInvalidTestScript,
+			fmt.Sprintf("Load Test inline test script cannot be stored, ConfigMap %s already exists and is not owned by the Load Test", found.Name))
+	}
+
+	if found.Data[TestScriptFilename] != instance.Spec.TestScript.Inline {
+		found.Data = configMap.Data
+		if err := r.Update(ctx, found); err != nil {
+			logger.Error(err, "Failed to update test script ConfigMap", "ConfigMap.Namespace", found.Namespace, "ConfigMap.Name", found.Name)
+			return &ctrl.Result{}, err
+		}
+	}
+
+	return nil, nil
+}
+
+// externalConfigRef references an external ConfigMap required by a test script
+// along with the LoadTest field it was declared in.
+type externalConfigRef struct {
+	configMap string
+	field     string
+}
+
+// externalConfigRefs returns the payload and processor ConfigMaps
+// defined in the LoadTest Custom Resource's spec.testScript.external.
+func externalConfigRefs(v *lt.LoadTest) []externalConfigRef {
+	var refs []externalConfigRef
+
+	external := v.Spec.TestScript.External
+	if external == nil {
+		return refs
+	}
+
+	if external.Processor != nil {
+		if external.Processor.Main.ConfigMap != "" {
+			refs = append(refs, externalConfigRef{
+				configMap: external.Processor.Main.ConfigMap,
+				field:     ".spec.testScript.external.processor.main.configMap",
+			})
+		}
+		for _, cm := range external.Processor.Related.ConfigMaps {
+			refs = append(refs, externalConfigRef{
+				configMap: cm,
+				field:     ".spec.testScript.external.processor.related.configMaps",
+			})
+		}
+	}
+
+	if external.Payload != nil {
+		for _, cm := range external.Payload.ConfigMaps {
+			refs = append(refs, externalConfigRef{
+				configMap: cm,
+				field:     ".spec.testScript.external.payload.configMaps",
+			})
+		}
+	}
+
+	return refs
+}
+
+// ensureExternalConfig ensures the payload and processor ConfigMaps defined
+// in the LoadTest Custom Resource are available on the cluster.
+// If not, a Warning event is triggered for every missing ConfigMap.
+// These events are viewable when running: kubectl describe loadtest <loadtest-name>.
+func (r *LoadTestReconciler) ensureExternalConfig(ctx context.Context,
+	instance *lt.LoadTest,
+	logger logr.Logger) (*ctrl.Result, error) {
+	if launched(instance) {
+		// Workers read payload and processor files from the snapshot
+		return nil, nil
+	}
+
+	var missing []string
+
+	for _, ref := range externalConfigRefs(instance) {
+		found := &core.ConfigMap{}
+		err := r.Get(ctx, types.NamespacedName{
+			Name:      ref.configMap,
+			Namespace: instance.Namespace,
+		}, found)
+
+		if err != nil && errors.IsNotFound(err) {
+			logger.Error(err, "External ConfigMap is missing", "ConfigMap", ref.configMap, "Field", ref.field)
+			r.Recorder.Eventf(instance, "Warning", ReasonMissingExternalConfig, "Load Test external ConfigMap %s is missing, see field %s", ref.configMap, ref.field)
+
+			missing = append(missing, ref.configMap)
+		} else if err != nil {
+			logger.Error(err, "Failed to get ConfigMap", "ConfigMap", ref.configMap, "Field", ref.field)
+			return &ctrl.Result{}, err
+		}
+	}
+
+	if len(missing) > 0 {
+		msg := fmt.Sprintf("Load Test external ConfigMaps are missing: %s", strings.Join(missing, ", "))
+		if err := r.failLoadTest(ctx, instance, ReasonMissingExternalConfig, msg); err != nil {
+			logger.Error(err, "Failed to update LoadTest status")
+			return &ctrl.Result{}, err
+		}
+
+		// External ConfigMaps are watched, the LoadTest is requeued once they're created
+		return &ctrl.Result{}, nil
+	}
+
+	// ConfigMaps located
 	return nil, nil
 }
+
+// testScript holds the parts of an Artillery test script inspected by the operator.
+type testScript struct {
+	Config struct {
+		Environments map[string]interface{} `yaml:"environments"`
+	} `yaml:"config"`
+
+	// root is the test script's parsed YAML document, used to report line numbers.
+	root yaml.Node
+}
+
+// parseTestScript parses the test script held by the test script ConfigMap's key.
+func parseTestScript(cm *core.ConfigMap, key string) (*testScript, error) {
+	data, ok := cm.Data[key]
+	if !ok {
+		return nil, fmt.Errorf("ConfigMap %s has no %s key", cm.Name, key)
+	}
+
+	script := &testScript{}
+	if err := yaml.Unmarshal([]byte(data), &script.root); err != nil {
+		return nil, err
+	}
+	if err := script.root.Decode(script); err != nil {
+		return nil, err
+	}
+
+	return script, nil
+}
+
+// scriptError describes an invalid part of a test script, along with its line number.
+type scriptError struct {
+	line    int
+	message string
+}
+
+func (e scriptError) Error() string {
+	return fmt.Sprintf("line %d: %s", e.line, e.message)
+}
+
+// validate ensures the test script defines a target, load phases and scenarios,
+// as required by Artillery to run a test.
+// The target and load phases can also be defined by the provided environment.
+func (s *testScript) validate(environment string) []scriptError {
+	if s.root.Kind != yaml.DocumentNode || len(s.root.Content) == 0 {
+		return []scriptError{{line: 1, message: "test script is empty"}}
+	}
+
+	root := s.root.Content[0]
+	var errs []scriptError
+
+	config := mappingValue(root, "config")
+	switch {
+	case config == nil:
+		errs = append(errs, scriptError{line: root.Line, message: "config is required"})
+	case config.Kind != yaml.MappingNode:
+		errs = append(errs, scriptError{line: config.Line, message: "config must be a mapping"})
+	default:
+		env := mappingValue(mappingValue(config, "environments"), environment)
+		errs = append(errs, validateTarget(config, env)...)
+		errs = append(errs, validatePhases(config, env)...)
+	}
+
+	return append(errs, validateScenarios(root)...)
+}
+
+// validateTarget ensures a target is defined by the test script's config, or the selected environment.
+func validateTarget(config *yaml.Node, env *yaml.Node) []scriptError {
+	for _, n := range []*yaml.Node{mappingValue(env, "target"), mappingValue(config, "target")} {
+		if n != nil && n.Kind == yaml.ScalarNode && n.Value != "" {
+			return nil
+		}
+	}
+	return []scriptError{{line: config.Line, message: "config.target is required"}}
+}
+
+// validatePhases ensures load phases are defined by the selected environment or the test script's config,
+// and that every phase has a duration or pause.
+func validatePhases(config *yaml.Node, env *yaml.Node) []scriptError {
+	field, phases := "config.phases", mappingValue(config, "phases")
+	if envPhases := mappingValue(env, "phases"); envPhases != nil {
+		field, phases = "config.environments phases", envPhases
+	}
+
+	if phases == nil {
+		return []scriptError{{line: config.Line, message: "config.phases is required"}}
+	}
+	if phases.Kind != yaml.SequenceNode || len(phases.Content) == 0 {
+		return []scriptError{{line: phases.Line, message: field + " must be a non-empty list"}}
+	}
+
+	var errs []scriptError
+	for i, phase := range phases.Content {
+		if mappingValue(phase, "duration") == nil && mappingValue(phase, "pause") == nil {
+			errs = append(errs, scriptError{line: phase.Line, message: fmt.Sprintf("%s[%d] must set a duration or pause", field, i)})
+		}
+	}
+	return errs
+}
+
+// validateScenarios ensures the test script defines scenarios, and that every scenario has a flow.
+// Scenarios run by other engines, e.g. playwright, are not required to have a flow.
+func validateScenarios(root *yaml.Node) []scriptError {
+	scenarios := mappingValue(root, "scenarios")
+	if scenarios == nil {
+		return []scriptError{{line: root.Line, message: "scenarios is required"}}
+	}
+	if scenarios.Kind != yaml.SequenceNode || len(scenarios.Content) == 0 {
+		return []scriptError{{line: scenarios.Line, message: "scenarios must be a non-empty list"}}
+	}
+
+	var errs []scriptError
+	for i, scenario := range scenarios.Content {
+		if scenario.Kind != yaml.MappingNode {
+			errs = append(errs, scriptError{line: scenario.Line, message: fmt.Sprintf("scenarios[%d] must be a mapping", i)})
+			continue
+		}
+		if mappingValue(scenario, "engine") != nil {
+			continue
+		}
+		if flow := mappingValue(scenario, "flow"); flow == nil || flow.Kind != yaml.SequenceNode {
+			errs = append(errs, scriptError{line: scenario.Line, message: fmt.Sprintf("scenarios[%d].flow must be a list", i)})
+		}
+	}
+	return errs
+}
+
+// mappingValue returns the value of the provided key in a YAML mapping, or nil if it's not found.
+func mappingValue(n *yaml.Node, key string) *yaml.Node {
+	if n == nil || n.Kind != yaml.MappingNode || key == "" {
+		return nil
+	}
+	for i := 0; i+1 < len(n.Content); i += 2 {
+		if n.Content[i].Value == key {
+			return n.Content[i+1]
+		}
+	}
+	return nil
+}
+
+// scriptErrorsMessage joins script errors into a single message.
+func scriptErrorsMessage(errs []scriptError) string {
+	var out []string
+	for _, err := range errs {
+		out = append(out, err.Error())
+	}
+	return strings.Join(out, "; ")
+}
+
+// environments returns the sorted names of all environments defined by the test script.
+func (s *testScript) environments() []string {
+	var out []string
+	for name := range s.Config.Environments {
+		out = append(out, name)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// ensureEnvironment ensures the environment defined in the LoadTest Custom Resource
+// exists in the test script's config.environments.
+// If not, the LoadTest is rejected and its workers are not created.
+func (r *LoadTestReconciler) ensureEnvironment(ctx context.Context,
+	instance *lt.LoadTest,
+	logger logr.Logger) (*ctrl.Result, error) {
+	environment := instance.Spec.Environment
+	if environment == "" || launched(instance) {
+		return nil, nil
+	}
+
+	found, err := r.testScriptConfigMap(ctx, instance)
+	if err != nil {
+		logger.Error(err, "Failed to get ConfigMap", "Testscript.Config.ConfigMap", testScriptConfigMapName(instance))
+		return &ctrl.Result{}, err
+	}
+
+	script, err := parseTestScript(found, testScriptKey(instance))
+	if err != nil {
+		return r.rejectLoadTest(ctx, instance, logger, ReasonInvalidTestScript,
+			fmt.Sprintf("Load Test test script could not be parsed: %s", err))
+	}
+
+	environments := script.environments()
+	if funk.ContainsString(environments, environment) {
+		return nil, nil
+	}
+
+	msg := fmt.Sprintf("Load Test environment %q is not defined in the test script's config.environments", environment)
+	if len(environments) > 0 {
+		msg = fmt.Sprintf("%s, available environments: %s", msg, strings.Join(environments, ", "))
+	}
+
+	return r.rejectLoadTest(ctx, instance, logger, ReasonEnvironmentNotFound, msg)
+}
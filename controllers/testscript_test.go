@@ -0,0 +1,280 @@
+/*
+ * Copyright (c) 2022.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0.
+ *
+ * If a copy of the MPL was not distributed with
+ * this file, You can obtain one at
+ *
+ *   http://mozilla.org/MPL/2.0/
+ */
+
+package controllers
+
+import (
+	"os"
+	"reflect"
+	"testing"
+
+	lt "github.com/artilleryio/artillery-operator/api/v1alpha1"
+	core "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+)
+
+func TestTestScriptValidate(t *testing.T) {
+	tests := []struct {
+		name        string
+		script      string
+		environment string
+		want        []string
+	}{
+		{
+			name:   "valid",
+			script: validTestScript,
+		},
+		{
+			name: "target and phases set by environment",
+			script: `config:
+  environments:
+    staging:
+      target: "http://staging:8080"
+      phases:
+        - pause: 10
+scenarios:
+  - flow:
+      - get:
+          url: "/"
+`,
+			environment: "staging",
+		},
+		{
+			name: "scenario run by another engine",
+			script: `config:
+  target: "http://localhost:8080"
+  phases:
+    - duration: 60
+scenarios:
+  - engine: playwright
+    testFunction: "run"
+`,
+		},
+		{
+			name:   "empty",
+			script: "",
+			want:   []string{"line 1: test script is empty"},
+		},
+		{
+			name:   "missing config and scenarios",
+			script: "before:\n  flow: []\n",
+			want:   []string{"line 1: config is required", "line 1: scenarios is required"},
+		},
+		{
+			name: "missing target and phases",
+			script: `config:
+  environments:
+    dev:
+      target: "http://localhost:8080"
+scenarios:
+  - flow: []
+`,
+			want: []string{"line 2: config.target is required", "line 2: config.phases is required"},
+		},
+		{
+			name: "phase without duration",
+			script: `config:
+  target: "http://localhost:8080"
+  phases:
+    - duration: 60
+    - arrivalRate: 10
+scenarios:
+  - flow: []
+`,
+			want: []string{"line 5: config.phases[1] must set a duration or pause"},
+		},
+		{
+			name: "empty scenarios and scenario without flow",
+			script: `config:
+  target: "http://localhost:8080"
+  phases: []
+scenarios:
+  - name: "no flow"
+`,
+			want: []string{"line 3: config.phases must be a non-empty list", "line 5: scenarios[0].flow must be a list"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			script, err := parseTestScript(scriptConfigMap(tt.script), TestScriptFilename)
+			if err != nil {
+				t.Fatalf("parseTestScript() error = %v", err)
+			}
+
+			var got []string
+			for _, err := range script.validate(tt.environment) {
+				got = append(got, err.Error())
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("validate() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTestScriptValidateExamples(t *testing.T) {
+	for _, path := range []string{
+		"../hack/examples/basic-loadtest/test-script.yaml",
+		"../hack/examples/published-metrics-loadtest/test-script.yaml",
+	} {
+		t.Run(path, func(t *testing.T) {
+			data, err := os.ReadFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			script, err := parseTestScript(scriptConfigMap(string(data)), TestScriptFilename)
+			if err != nil {
+				t.Fatalf("parseTestScript() error = %v", err)
+			}
+			if errs := script.validate(""); len(errs) > 0 {
+				t.Errorf("validate() = %v, want no errors", errs)
+			}
+		})
+	}
+}
+
+func TestTestScriptSource(t *testing.T) {
+	tests := []struct {
+		name          string
+		testScript    lt.TestScript
+		wantConfigMap string
+		wantArgs      []string
+	}{
+		{
+			name:          "ConfigMap",
+			testScript:    lt.TestScript{Config: lt.Config{ConfigMap: "scripts"}},
+			wantConfigMap: "scripts",
+			wantArgs:      []string{"run", "/data/test-script.yaml"},
+		},
+		{
+			name:          "ConfigMap key",
+			testScript:    lt.TestScript{Config: lt.Config{ConfigMap: "scripts", Key: "smoke.yaml"}},
+			wantConfigMap: "scripts",
+			wantArgs:      []string{"run", "/data/smoke.yaml"},
+		},
+		{
+			name:          "inline",
+			testScript:    lt.TestScript{Inline: validTestScript},
+			wantConfigMap: "nightly-test-script",
+			wantArgs:      []string{"run", "/data/test-script.yaml"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &lt.LoadTest{
+				ObjectMeta: metav1.ObjectMeta{Name: "nightly"},
+				Spec:       lt.LoadTestSpec{TestScript: tt.testScript},
+			}
+
+			if got := testScriptConfigMapName(v); got != tt.wantConfigMap {
+				t.Errorf("testScriptConfigMapName() = %q, want %q", got, tt.wantConfigMap)
+			}
+			if got := workerArgs(v); !reflect.DeepEqual(got, tt.wantArgs) {
+				t.Errorf("workerArgs() = %q, want %q", got, tt.wantArgs)
+			}
+		})
+	}
+}
+
+func TestInlineTestScriptConfigMap(t *testing.T) {
+	v := &lt.LoadTest{
+		ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "perf"},
+		Spec:       lt.LoadTestSpec{TestScript: lt.TestScript{Inline: validTestScript}},
+	}
+
+	cm := inlineTestScriptConfigMap(v)
+	if cm.Name != "nightly-test-script" || cm.Namespace != "perf" {
+		t.Errorf("inlineTestScriptConfigMap() = %s/%s, want perf/nightly-test-script", cm.Namespace, cm.Name)
+	}
+
+	script, err := parseTestScript(cm, testScriptKey(v))
+	if err != nil {
+		t.Fatalf("parseTestScript() error = %v", err)
+	}
+	if errs := script.validate(""); len(errs) > 0 {
+		t.Errorf("validate() = %v, want no errors", errs)
+	}
+}
+
+func TestConfigMapRefs(t *testing.T) {
+	tests := []struct {
+		name       string
+		testScript lt.TestScript
+		env        []core.EnvVar
+		envFrom    []core.EnvFromSource
+		want       []string
+	}{
+		{
+			name:       "test script ConfigMap",
+			testScript: lt.TestScript{Config: lt.Config{ConfigMap: "test-script"}},
+			want:       []string{"test-script"},
+		},
+		{
+			name: "external ConfigMaps",
+			testScript: lt.TestScript{
+				Config: lt.Config{ConfigMap: "test-script"},
+				External: &lt.External{
+					Payload: &lt.Payload{ConfigMaps: []string{"users-csv", "test-script"}},
+					Processor: &lt.Processor{
+						Main: lt.Main{ConfigMap: "processor-js"},
+					},
+				},
+			},
+			want: []string{"test-script", "processor-js", "users-csv"},
+		},
+		{
+			name:       "inline test script",
+			testScript: lt.TestScript{Inline: validTestScript},
+		},
+		{
+			name:       "env ConfigMaps",
+			testScript: lt.TestScript{Config: lt.Config{ConfigMap: "test-script"}},
+			env: []core.EnvVar{
+				configMapKeyEnv("TARGET", "settings", "target", false),
+				configMapKeyEnv("DEBUG", "debug", "enabled", true),
+				secretKeyEnv("API_TOKEN", "api", "token", false),
+			},
+			envFrom: []core.EnvFromSource{{ConfigMapRef: &core.ConfigMapEnvSource{LocalObjectReference: core.LocalObjectReference{Name: "common"}}}},
+			want:    []string{"test-script", "settings", "common"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &lt.LoadTest{Spec: lt.LoadTestSpec{TestScript: tt.testScript, Env: tt.env, EnvFrom: tt.envFrom}}
+			if got := configMapRefs(v); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("configMapRefs() = %q, want %q", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestTestScriptRevision(t *testing.T) {
+	cm := scriptConfigMap("hello")
+	cm.Name, cm.ResourceVersion = "test-script", "42"
+
+	want := &lt.LoadTestScriptStatus{
+		ConfigMap:       "test-script",
+		ResourceVersion: "42",
+		Hash:            "sha256:2cf24dba5fb0a30e26e83b2ac5b9e29e1b161e5c1fa7425e73043362938b9824",
+	}
+	if got := testScriptRevision(cm, TestScriptFilename); !reflect.DeepEqual(got, want) {
+		t.Errorf("testScriptRevision() = %+v, want %+v", got, want)
+	}
+}
+
+func scriptConfigMap(script string) *core.ConfigMap {
+	return &core.ConfigMap{Data: map[string]string{TestScriptFilename: script}}
+}
@@ -0,0 +1,211 @@
+/*
+ * Copyright (c) 2022.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0.
+ *
+ * If a copy of the MPL was not distributed with
+ * this file, You can obtain one at
+ *
+ *   http://mozilla.org/MPL/2.0/
+ */
+
+package controllers
+
+import (
+	"context"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	lt "github.com/artilleryio/artillery-operator/api/v1alpha1"
+	"github.com/go-logr/logr"
+	corev1 "k8s.io/api/core/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+)
+
+// thresholdExpr matches a threshold expression, e.g. "p95 < 300ms".
+var thresholdExpr = regexp.MustCompile(`^\s*([A-Za-z0-9]+)\s*(<=|>=|==|<|>)\s*([0-9]+(?:\.[0-9]+)?)\s*(ms|s|%)?\s*$`)
+
+// thresholdUnit is the kind of unit a threshold metric is measured in.
+type thresholdUnit uint
+
+const (
+	unitCount thresholdUnit = iota
+	unitDuration
+	unitPercent
+)
+
+// thresholdMetric reads a metric from an aggregated test report.
+// Duration metrics are read in milliseconds, percent metrics as a percentage.
+type thresholdMetric struct {
+	unit  thresholdUnit
+	value func(r *lt.LoadTestReport) float64
+}
+
+// thresholdMetrics lists the metrics thresholds can be evaluated against.
+var thresholdMetrics = map[string]thresholdMetric{
+	"min":    {unit: unitDuration, value: func(r *lt.LoadTestReport) float64 { return ms(r.Latency.Min.Duration) }},
+	"max":    {unit: unitDuration, value: func(r *lt.LoadTestReport) float64 { return ms(r.Latency.Max.Duration) }},
+	"p50":    {unit: unitDuration, value: func(r *lt.LoadTestReport) float64 { return ms(r.Latency.P50.Duration) }},
+	"p95":    {unit: unitDuration, value: func(r *lt.LoadTestReport) float64 { return ms(r.Latency.P95.Duration) }},
+	"p99":    {unit: unitDuration, value: func(r *lt.LoadTestReport) float64 { return ms(r.Latency.P99.Duration) }},
+	"errors": {unit: unitCount, value: func(r *lt.LoadTestReport) float64 { return float64(r.Errors) }},
+	"errorRate": {unit: unitPercent, value: func(r *lt.LoadTestReport) float64 {
+		if r.Requests == 0 {
+			if r.Errors > 0 {
+				return 100
+			}
+			return 0
+		}
+		return float64(r.Errors) / float64(r.Requests) * 100
+	}},
+	"requests":     {unit: unitCount, value: func(r *lt.LoadTestReport) float64 { return float64(r.Requests) }},
+	"responses":    {unit: unitCount, value: func(r *lt.LoadTestReport) float64 { return float64(r.Responses) }},
+	"rps":          {unit: unitCount, value: func(r *lt.LoadTestReport) float64 { return float64(r.RequestRate) }},
+	"vusersFailed": {unit: unitCount, value: func(r *lt.LoadTestReport) float64 { return float64(r.VUsers.Failed) }},
+}
+
+// threshold is a parsed LoadTest threshold.
+type threshold struct {
+	expr     string
+	metric   string
+	operator string
+	value    float64
+}
+
+// parseThreshold parses a threshold expression, e.g. "p95 < 300ms" or "errorRate < 1%".
+// Duration values are normalised to milliseconds.
+func parseThreshold(expr lt.Threshold) (threshold, error) {
+	m := thresholdExpr.FindStringSubmatch(string(expr))
+	if m == nil {
+		return threshold{}, fmt.Errorf("threshold %q must be in the form \"<metric> <operator> <value>\"", expr)
+	}
+
+	metric, ok := thresholdMetrics[m[1]]
+	if !ok {
+		return threshold{}, fmt.Errorf("threshold %q has unknown metric %s", expr, m[1])
+	}
+
+	value, err := strconv.ParseFloat(m[3], 64)
+	if err != nil {
+		return threshold{}, fmt.Errorf("threshold %q has invalid value %s", expr, m[3])
+	}
+
+	switch unit := m[4]; {
+	case unit == "s" && metric.unit == unitDuration:
+		value *= 1000
+	case unit == "ms" && metric.unit == unitDuration,
+		unit == "%" && metric.unit == unitPercent,
+		unit == "":
+	default:
+		return threshold{}, fmt.Errorf("threshold %q has unit %s which is not valid for metric %s", expr, unit, m[1])
+	}
+
+	return threshold{
+		expr:     strings.TrimSpace(string(expr)),
+		metric:   m[1],
+		operator: m[2],
+		value:    value,
+	}, nil
+}
+
+// evaluate returns whether the aggregated test report meets the threshold, along with the actual metric value.
+func (t threshold) evaluate(r *lt.LoadTestReport) (bool, float64) {
+	actual := thresholdMetrics[t.metric].value(r)
+
+	switch t.operator {
+	case "<":
+		return actual < t.value, actual
+	case "<=":
+		return actual <= t.value, actual
+	case ">":
+		return actual > t.value, actual
+	case ">=":
+		return actual >= t.value, actual
+	default:
+		return actual == t.value, actual
+	}
+}
+
+// format formats a metric value using the threshold metric's unit.
+func (t threshold) format(value float64) string {
+	switch thresholdMetrics[t.metric].unit {
+	case unitDuration:
+		return strconv.FormatFloat(value, 'f', -1, 64) + "ms"
+	case unitPercent:
+		return strconv.FormatFloat(value, 'f', 2, 64) + "%"
+	default:
+		return strconv.FormatFloat(value, 'f', -1, 64)
+	}
+}
+
+func ms(d time.Duration) float64 {
+	return float64(d) / float64(time.Millisecond)
+}
+
+// ensureThresholds ensures the LoadTest's thresholds can be evaluated.
+// LoadTests with invalid thresholds are rejected before any workers are created.
+func (r *LoadTestReconciler) ensureThresholds(ctx context.Context,
+	instance *lt.LoadTest,
+	logger logr.Logger) (*ctrl.Result, error) {
+	var invalid []string
+	for _, expr := range instance.Spec.Thresholds {
+		if _, err := parseThreshold(expr); err != nil {
+			invalid = append(invalid, err.Error())
+		}
+	}
+
+	if len(invalid) > 0 {
+		return r.rejectLoadTest(ctx, instance, logger, ReasonInvalidThreshold,
+			fmt.Sprintf("Load Test thresholds are invalid: %s", strings.Join(invalid, "; ")))
+	}
+
+	// Thresholds are valid
+	return nil, nil
+}
+
+// configureSLO evaluates the LoadTest's thresholds against its aggregated test report
+// and sets the SLOMet condition accordingly.
+// A Warning event is published for every violated threshold the first time thresholds are evaluated.
+func configureSLO(v *lt.LoadTest, r *LoadTestReconciler) {
+	if len(v.Spec.Thresholds) == 0 {
+		return
+	}
+	if current, ok := conditionsMap(v.Status.Conditions)[lt.LoadTestSLOMet]; ok && current.Status != corev1.ConditionUnknown {
+		// Thresholds have already been evaluated
+		return
+	}
+
+	if v.Status.Report == nil {
+		setCondition(v, lt.LoadTestSLOMet, corev1.ConditionUnknown, ReasonReportUnavailable,
+			"Load Test thresholds could not be evaluated, no worker test reports were found")
+		return
+	}
+
+	var violations []string
+	for _, expr := range v.Spec.Thresholds {
+		t, err := parseThreshold(expr)
+		if err != nil {
+			// Invalid thresholds are rejected before workers are created
+			continue
+		}
+
+		if ok, actual := t.evaluate(v.Status.Report); !ok {
+			violation := fmt.Sprintf("%s (actual %s)", t.expr, t.format(actual))
+			r.Recorder.Eventf(v, "Warning", ReasonSLOViolated, "Load Test threshold violated: %s", violation)
+			violations = append(violations, violation)
+		}
+	}
+
+	if len(violations) > 0 {
+		setCondition(v, lt.LoadTestSLOMet, corev1.ConditionFalse, ReasonSLOViolated,
+			fmt.Sprintf("Load Test thresholds violated: %s", strings.Join(violations, ", ")))
+		return
+	}
+
+	r.Recorder.Event(v, "Normal", ReasonSLOMet, "Load Test met all thresholds")
+	setCondition(v, lt.LoadTestSLOMet, corev1.ConditionTrue, ReasonSLOMet, "Load Test met all thresholds")
+}
@@ -0,0 +1,130 @@
+/*
+ * Copyright (c) 2022.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0.
+ *
+ * If a copy of the MPL was not distributed with
+ * this file, You can obtain one at
+ *
+ *   http://mozilla.org/MPL/2.0/
+ */
+
+package controllers
+
+import (
+	"testing"
+	"time"
+
+	lt "github.com/artilleryio/artillery-operator/api/v1alpha1"
+	corev1 "k8s.io/api/core/v1"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/client-go/tools/record"
+)
+
+func TestParseThreshold(t *testing.T) {
+	tests := []struct {
+		expr      lt.Threshold
+		wantValue float64
+		wantErr   bool
+	}{
+		{expr: "p95 < 300ms", wantValue: 300},
+		{expr: "p99<=1.5s", wantValue: 1500},
+		{expr: " max < 2000 ", wantValue: 2000},
+		{expr: "errorRate < 1%", wantValue: 1},
+		{expr: "rps >= 50", wantValue: 50},
+		{expr: "vusersFailed == 0", wantValue: 0},
+		{expr: "latency < 300ms", wantErr: true},
+		{expr: "errorRate < 1ms", wantErr: true},
+		{expr: "p95 < 10%", wantErr: true},
+		{expr: "p95 300ms", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(string(tt.expr), func(t *testing.T) {
+			got, err := parseThreshold(tt.expr)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseThreshold() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && got.value != tt.wantValue {
+				t.Errorf("parseThreshold() value = %v, want %v", got.value, tt.wantValue)
+			}
+		})
+	}
+}
+
+func TestConfigureSLO(t *testing.T) {
+	report := &lt.LoadTestReport{
+		Requests: 200,
+		Errors:   4,
+		Latency: lt.LoadTestLatency{
+			P95: metav1.Duration{Duration: 412 * time.Millisecond},
+			P99: metav1.Duration{Duration: 900 * time.Millisecond},
+		},
+	}
+
+	tests := []struct {
+		name        string
+		thresholds  []lt.Threshold
+		report      *lt.LoadTestReport
+		wantStatus  corev1.ConditionStatus
+		wantReason  string
+		wantMessage string
+		wantEvents  int
+	}{
+		{
+			name:        "all thresholds met",
+			thresholds:  []lt.Threshold{"p95 < 500ms", "errorRate <= 2%"},
+			report:      report,
+			wantStatus:  corev1.ConditionTrue,
+			wantReason:  ReasonSLOMet,
+			wantMessage: "Load Test met all thresholds",
+			wantEvents:  1,
+		},
+		{
+			name:        "thresholds violated",
+			thresholds:  []lt.Threshold{"p95 < 300ms", "errorRate < 1%", "p99 < 1s"},
+			report:      report,
+			wantStatus:  corev1.ConditionFalse,
+			wantReason:  ReasonSLOViolated,
+			wantMessage: "Load Test thresholds violated: p95 < 300ms (actual 412ms), errorRate < 1% (actual 2.00%)",
+			wantEvents:  2,
+		},
+		{
+			name:       "no report",
+			thresholds: []lt.Threshold{"p95 < 300ms"},
+			wantStatus: corev1.ConditionUnknown,
+			wantReason: ReasonReportUnavailable,
+			wantMessage: "Load Test thresholds could not be evaluated, " +
+				"no worker test reports were found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			recorder := record.NewFakeRecorder(10)
+			r := &LoadTestReconciler{Recorder: recorder}
+			v := &lt.LoadTest{
+				Spec:   lt.LoadTestSpec{Thresholds: tt.thresholds},
+				Status: lt.LoadTestStatus{Report: tt.report},
+			}
+
+			configureSLO(v, r)
+
+			condition := conditionsMap(v.Status.Conditions)[lt.LoadTestSLOMet]
+			if condition.Status != tt.wantStatus || condition.Reason != tt.wantReason || condition.Message != tt.wantMessage {
+				t.Errorf("SLOMet condition = (%v, %q, %q), want (%v, %q, %q)",
+					condition.Status, condition.Reason, condition.Message, tt.wantStatus, tt.wantReason, tt.wantMessage)
+			}
+			if got := len(recorder.Events); got != tt.wantEvents {
+				t.Errorf("published %d events, want %d", got, tt.wantEvents)
+			}
+
+			// Thresholds are only evaluated once
+			configureSLO(v, r)
+			if tt.wantStatus != corev1.ConditionUnknown && len(recorder.Events) != tt.wantEvents {
+				t.Errorf("published %d events after re-evaluation, want %d", len(recorder.Events), tt.wantEvents)
+			}
+		})
+	}
+}
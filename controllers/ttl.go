@@ -0,0 +1,138 @@
+/*
+ * Copyright (c) 2022.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0.
+ *
+ * If a copy of the MPL was not distributed with
+ * this file, You can obtain one at
+ *
+ *   http://mozilla.org/MPL/2.0/
+ */
+
+package controllers
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	lt "github.com/artilleryio/artillery-operator/api/v1alpha1"
+	"github.com/go-logr/logr"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	ctrl "sigs.k8s.io/controller-runtime"
+	"sigs.k8s.io/controller-runtime/pkg/client"
+)
+
+// ttlSecondsAfterFinished returns how long a finished LoadTest and its worker Job are kept,
+// from its spec.ttlSecondsAfterFinished or the operator's default TTL.
+// The worker Job has no TTL of its own, it's deleted along with the LoadTest once its status is recorded.
+// It returns nil if they're kept until deleted.
+func (r *LoadTestReconciler) ttlSecondsAfterFinished(v *lt.LoadTest) *int32 {
+	if v.Spec.TTLSecondsAfterFinished != nil {
+		return v.Spec.TTLSecondsAfterFinished
+	}
+	if r.TTLAfterFinished > 0 {
+		ttl := int32(r.TTLAfterFinished / time.Second)
+		return &ttl
+	}
+	return nil
+}
+
+// ttlRemaining returns how long until a finished LoadTest expires, or zero if it doesn't.
+func (r *LoadTestReconciler) ttlRemaining(v *lt.LoadTest, now time.Time) time.Duration {
+	ttl := r.ttlSecondsAfterFinished(v)
+	if ttl == nil || v.Status.CompletionTime == nil {
+		return 0
+	}
+	if remaining := v.Status.CompletionTime.Add(time.Duration(*ttl) * time.Second).Sub(now); remaining > 0 {
+		return remaining
+	}
+	// Expired, requeue right away
+	return time.Second
+}
+
+// finishedRequeueAfter returns when a finished LoadTest must be reconciled again,
+// either to delete its published metrics or once its TTL expires. It returns zero if it needn't be requeued.
+func (r *LoadTestReconciler) finishedRequeueAfter(v *lt.LoadTest, now time.Time) time.Duration {
+	requeueAfter := metricsRetentionRemaining(v, now)
+	if ttl := r.ttlRemaining(v, now); ttl > 0 && (requeueAfter == 0 || ttl < requeueAfter) {
+		requeueAfter = ttl
+	}
+	return requeueAfter
+}
+
+// ensureTTL deletes a finished LoadTest once its TTL expires, and prunes the namespace's finished LoadTests
+// beyond the operator's history limit. Owned objects, e.g. the worker Job, are deleted along with the LoadTest.
+func (r *LoadTestReconciler) ensureTTL(ctx context.Context,
+	instance *lt.LoadTest,
+	logger logr.Logger) (*ctrl.Result, error) {
+	if instance.Status.CompletionTime == nil {
+		return nil, nil
+	}
+
+	if err := r.pruneHistory(ctx, instance.Namespace, logger); err != nil {
+		logger.Error(err, "Failed to prune finished LoadTests", "Namespace", instance.Namespace)
+		return &ctrl.Result{}, err
+	}
+
+	ttl := r.ttlSecondsAfterFinished(instance)
+	if ttl == nil || time.Now().Before(instance.Status.CompletionTime.Add(time.Duration(*ttl)*time.Second)) {
+		return nil, nil
+	}
+
+	if err := r.Delete(ctx, instance, client.PropagationPolicy(metav1.DeletePropagationBackground)); client.IgnoreNotFound(err) != nil {
+		logger.Error(err, "Failed to delete expired LoadTest")
+		return &ctrl.Result{}, err
+	}
+	logger.Info("Deleted expired LoadTest", "CompletionTime", instance.Status.CompletionTime, "TTLSecondsAfterFinished", *ttl)
+	return &ctrl.Result{}, nil
+}
+
+// pruneHistory deletes the oldest finished LoadTests of a namespace beyond the operator's history limit.
+// LoadTests created by a LoadTestSchedule are left to their schedule's own history limits.
+func (r *LoadTestReconciler) pruneHistory(ctx context.Context, namespace string, logger logr.Logger) error {
+	if r.HistoryLimit <= 0 {
+		return nil
+	}
+
+	list := &lt.LoadTestList{}
+	if err := r.List(ctx, list, client.InNamespace(namespace)); err != nil {
+		return err
+	}
+
+	for _, v := range expiredHistory(list.Items, r.HistoryLimit) {
+		v := v
+		if err := r.Delete(ctx, &v, client.PropagationPolicy(metav1.DeletePropagationBackground)); err != nil && !errors.IsNotFound(err) {
+			return err
+		}
+		logger.Info("Pruned LoadTest beyond namespace history limit",
+			"LoadTest.Name", v.Name, "CompletionTime", v.Status.CompletionTime, "HistoryLimit", r.HistoryLimit)
+	}
+	return nil
+}
+
+// expiredHistory returns the finished LoadTests beyond the provided limit, oldest last.
+// LoadTests being deleted or created by a LoadTestSchedule are ignored.
+func expiredHistory(loadTests []lt.LoadTest, limit int) []lt.LoadTest {
+	var finished []lt.LoadTest
+	for _, v := range loadTests {
+		if v.Status.CompletionTime == nil || !v.DeletionTimestamp.IsZero() {
+			continue
+		}
+		if owner := metav1.GetControllerOf(&v); owner != nil && owner.Kind == "LoadTestSchedule" {
+			continue
+		}
+		finished = append(finished, v)
+	}
+
+	if len(finished) <= limit {
+		return nil
+	}
+
+	sort.SliceStable(finished, func(i, j int) bool {
+		return finished[j].Status.CompletionTime.Before(finished[i].Status.CompletionTime)
+	})
+	return finished[limit:]
+}
@@ -0,0 +1,184 @@
+/*
+ * Copyright (c) 2022.
+ *
+ * This Source Code Form is subject to the terms of the Mozilla Public
+ * License, v. 2.0.
+ *
+ * If a copy of the MPL was not distributed with
+ * this file, You can obtain one at
+ *
+ *   http://mozilla.org/MPL/2.0/
+ */
+
+package controllers
+
+import (
+	"context"
+	"reflect"
+	"testing"
+	"time"
+
+	lt "github.com/artilleryio/artillery-operator/api/v1alpha1"
+	"github.com/go-logr/logr"
+	v1 "k8s.io/api/batch/v1"
+	"k8s.io/apimachinery/pkg/api/errors"
+	metav1 "k8s.io/apimachinery/pkg/apis/meta/v1"
+	"k8s.io/apimachinery/pkg/types"
+	"k8s.io/client-go/tools/record"
+	"sigs.k8s.io/controller-runtime/pkg/client/fake"
+)
+
+func finishedLoadTest(name string, completed time.Time) *lt.LoadTest {
+	completion := metav1.NewTime(completed)
+	return &lt.LoadTest{
+		ObjectMeta: metav1.ObjectMeta{Name: name, Namespace: "perf"},
+		Status:     lt.LoadTestStatus{CompletionTime: &completion},
+	}
+}
+
+func int32Ptr(i int32) *int32 { return &i }
+
+func int64Ptr(i int64) *int64 { return &i }
+
+func TestTTLSecondsAfterFinished(t *testing.T) {
+	tests := []struct {
+		name       string
+		spec       *int32
+		defaultTTL time.Duration
+		want       *int32
+	}{
+		{name: "kept until deleted"},
+		{name: "operator default", defaultTTL: time.Hour, want: int32Ptr(3600)},
+		{name: "spec overrides default", spec: int32Ptr(0), defaultTTL: time.Hour, want: int32Ptr(0)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := &LoadTestReconciler{Scheme: testScheme(), TTLAfterFinished: tt.defaultTTL}
+			v := &lt.LoadTest{ObjectMeta: metav1.ObjectMeta{Name: "nightly", Namespace: "perf"}, Spec: lt.LoadTestSpec{TTLSecondsAfterFinished: tt.spec}}
+
+			if got := r.ttlSecondsAfterFinished(v); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("ttlSecondsAfterFinished() = %v, want %v", got, tt.want)
+			}
+			// Deleted along with the LoadTest, never before its status is recorded
+			if got := r.job(v).Spec.TTLSecondsAfterFinished; got != nil {
+				t.Errorf("job() TTLSecondsAfterFinished = %v, want nil", *got)
+			}
+		})
+	}
+}
+
+func TestFinishedRequeueAfter(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	v := finishedLoadTest("nightly", now.Add(-time.Minute))
+	v.Spec.TTLSecondsAfterFinished = int32Ptr(600)
+	v.Spec.Metrics = &lt.Metrics{Prometheus: &lt.PrometheusMetrics{Pushgateway: "http://pushgateway:9091", RetentionSeconds: int64Ptr(120)}}
+
+	r := &LoadTestReconciler{}
+	if got := r.finishedRequeueAfter(v, now); got != time.Minute {
+		t.Errorf("finishedRequeueAfter() = %v, want %v", got, time.Minute)
+	}
+
+	v.Spec.Metrics = nil
+	if got := r.finishedRequeueAfter(v, now); got != 9*time.Minute {
+		t.Errorf("finishedRequeueAfter() = %v, want %v", got, 9*time.Minute)
+	}
+
+	v.Status.CompletionTime = nil
+	if got := r.finishedRequeueAfter(v, now); got != 0 {
+		t.Errorf("finishedRequeueAfter() = %v, want 0 for a running LoadTest", got)
+	}
+}
+
+func TestEnsureTTL(t *testing.T) {
+	tests := []struct {
+		name        string
+		ttl         *int32
+		wantResult  bool
+		wantDeleted bool
+	}{
+		{name: "no TTL"},
+		{name: "not expired", ttl: int32Ptr(3600)},
+		{name: "expired", ttl: int32Ptr(30), wantResult: true, wantDeleted: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := finishedLoadTest("nightly", time.Now().Add(-time.Minute))
+			v.Spec.TTLSecondsAfterFinished = tt.ttl
+			r := &LoadTestReconciler{Client: fake.NewClientBuilder().WithScheme(testScheme()).WithObjects(v).Build()}
+
+			result, err := r.ensureTTL(context.Background(), v, logr.Discard())
+			if err != nil || (result != nil) != tt.wantResult {
+				t.Fatalf("ensureTTL() = %v, %v, want result %v", result, err, tt.wantResult)
+			}
+
+			err = r.Get(context.Background(), types.NamespacedName{Name: v.Name, Namespace: v.Namespace}, &lt.LoadTest{})
+			if deleted := errors.IsNotFound(err); deleted != tt.wantDeleted {
+				t.Errorf("ensureTTL() deleted = %v, want %v", deleted, tt.wantDeleted)
+			}
+		})
+	}
+}
+
+func TestExpiredHistory(t *testing.T) {
+	now := time.Date(2022, 3, 1, 12, 0, 0, 0, time.UTC)
+	deleting := metav1.NewTime(now)
+
+	running := &lt.LoadTest{ObjectMeta: metav1.ObjectMeta{Name: "running", Namespace: "perf"}}
+	controller := true
+	scheduled := finishedLoadTest("scheduled", now.Add(-5*time.Hour))
+	scheduled.OwnerReferences = []metav1.OwnerReference{{
+		APIVersion: lt.GroupVersion.String(), Kind: "LoadTestSchedule", Name: "nightly", UID: "uid", Controller: &controller,
+	}}
+	beingDeleted := finishedLoadTest("deleting", now.Add(-4*time.Hour))
+	beingDeleted.DeletionTimestamp = &deleting
+
+	loadTests := []lt.LoadTest{
+		*finishedLoadTest("oldest", now.Add(-3*time.Hour)),
+		*running,
+		*finishedLoadTest("newest", now.Add(-time.Hour)),
+		*scheduled,
+		*beingDeleted,
+		*finishedLoadTest("older", now.Add(-2*time.Hour)),
+	}
+
+	tests := []struct {
+		limit int
+		want  []string
+	}{
+		{limit: 3},
+		{limit: 2, want: []string{"oldest"}},
+		{limit: 1, want: []string{"older", "oldest"}},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, v := range expiredHistory(loadTests, tt.limit) {
+			got = append(got, v.Name)
+		}
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("expiredHistory(%d) = %v, want %v", tt.limit, got, tt.want)
+		}
+	}
+}
+
+func TestEnsureJobFinished(t *testing.T) {
+	v := finishedLoadTest("nightly", time.Now().Add(-time.Minute))
+	r := &LoadTestReconciler{
+		Client:   fake.NewClientBuilder().WithScheme(testScheme()).WithObjects(v).Build(),
+		Scheme:   testScheme(),
+		Recorder: record.NewFakeRecorder(10),
+	}
+
+	result, err := r.ensureJob(context.Background(), v, logr.Discard(), r.job(v))
+	if result == nil || err != nil {
+		t.Fatalf("ensureJob() = %v, %v, want a result", result, err)
+	}
+
+	err = r.Get(context.Background(), types.NamespacedName{Name: v.Name, Namespace: v.Namespace}, &v1.Job{})
+	if !errors.IsNotFound(err) {
+		t.Errorf("ensureJob() recreated the Job of a finished LoadTest")
+	}
+}
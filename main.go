@@ -14,8 +14,11 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"io"
 	"os"
+	"strconv"
+	"time"
 
 	"github.com/artilleryio/artillery-operator/internal/telemetry"
 	"github.com/go-logr/logr"
@@ -25,6 +28,7 @@ import (
 
 	"k8s.io/apimachinery/pkg/runtime"
 	utilruntime "k8s.io/apimachinery/pkg/util/runtime"
+	"k8s.io/client-go/kubernetes"
 	clientgoscheme "k8s.io/client-go/kubernetes/scheme"
 	ctrl "sigs.k8s.io/controller-runtime"
 	"sigs.k8s.io/controller-runtime/pkg/healthz"
@@ -53,15 +57,65 @@ func doClose(closer io.Closer, msg string, logger logr.Logger) {
 	}
 }
 
+// envOrDefault returns the value of the provided env var, or a default value if it is not set.
+func envOrDefault(key, defaultValue string) string {
+	if v, ok := os.LookupEnv(key); ok && v != "" {
+		return v
+	}
+	return defaultValue
+}
+
+// durationEnvOrDefault returns the duration held by the provided env var, or a default value if it is not set.
+func durationEnvOrDefault(key string, defaultValue time.Duration) time.Duration {
+	v := envOrDefault(key, defaultValue.String())
+	d, err := time.ParseDuration(v)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid %s duration %q: %s\n", key, v, err)
+		os.Exit(1)
+	}
+	return d
+}
+
+// intEnvOrDefault returns the integer held by the provided env var, or a default value if it is not set.
+func intEnvOrDefault(key string, defaultValue int) int {
+	v := envOrDefault(key, strconv.Itoa(defaultValue))
+	i, err := strconv.Atoi(v)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "invalid %s integer %q: %s\n", key, v, err)
+		os.Exit(1)
+	}
+	return i
+}
+
 func main() {
 	var metricsAddr string
 	var enableLeaderElection bool
 	var probeAddr string
+	var workerImage string
+	var enableWebhooks bool
+	var pendingTimeout time.Duration
+	var ttlAfterFinished time.Duration
+	var historyLimit int
 	flag.StringVar(&metricsAddr, "metrics-bind-address", ":8080", "The address the metric endpoint binds to.")
 	flag.StringVar(&probeAddr, "health-probe-bind-address", ":8081", "The address the probe endpoint binds to.")
 	flag.BoolVar(&enableLeaderElection, "leader-elect", false,
 		"Enable leader election for controller manager. "+
 			"Enabling this will ensure there is only one active controller manager.")
+	flag.StringVar(&workerImage, "worker-image", envOrDefault("ARTILLERY_WORKER_IMAGE", controllers.DefaultWorkerImage),
+		"The Artillery image used by workers when a LoadTest does not specify one. "+
+			"Can also be set using the ARTILLERY_WORKER_IMAGE env var.")
+	flag.BoolVar(&enableWebhooks, "enable-webhooks", envOrDefault("ENABLE_WEBHOOKS", "false") == "true",
+		"Enable the LoadTest defaulting and validating webhooks. Requires serving certificates, e.g. from cert-manager. "+
+			"Can also be set using the ENABLE_WEBHOOKS env var.")
+	flag.DurationVar(&pendingTimeout, "worker-pending-timeout", durationEnvOrDefault("WORKER_PENDING_TIMEOUT", controllers.DefaultPendingTimeout),
+		"The maximum time a worker pod can stay pending, e.g. unschedulable or unable to pull its image, before its LoadTest fails. "+
+			"Set to 0 to disable. Can also be set using the WORKER_PENDING_TIMEOUT env var.")
+	flag.DurationVar(&ttlAfterFinished, "ttl-after-finished", durationEnvOrDefault("TTL_AFTER_FINISHED", 0),
+		"How long finished LoadTests and their worker Jobs are kept when a LoadTest does not set spec.ttlSecondsAfterFinished. "+
+			"Set to 0 to keep them until deleted. Can also be set using the TTL_AFTER_FINISHED env var.")
+	flag.IntVar(&historyLimit, "history-limit", intEnvOrDefault("HISTORY_LIMIT", 0),
+		"The number of finished LoadTests kept per namespace, older ones are deleted. "+
+			"Set to 0 to disable. Can also be set using the HISTORY_LIMIT env var.")
 	opts := zap.Options{
 		Development: true,
 	}
@@ -84,12 +138,18 @@ func main() {
 	}
 
 	reconciler := &controllers.LoadTestReconciler{
-		Client:   mgr.GetClient(),
-		Scheme:   mgr.GetScheme(),
-		Recorder: mgr.GetEventRecorderFor("loadtest-controller"),
+		Client:           mgr.GetClient(),
+		Scheme:           mgr.GetScheme(),
+		Recorder:         mgr.GetEventRecorderFor("loadtest-controller"),
+		KubeClient:       kubernetes.NewForConfigOrDie(mgr.GetConfig()),
+		APIReader:        mgr.GetAPIReader(),
+		WorkerImage:      workerImage,
+		PendingTimeout:   pendingTimeout,
+		TTLAfterFinished: ttlAfterFinished,
+		HistoryLimit:     historyLimit,
 	}
 
-	telemetryConfig := telemetry.NewConfig(controllers.AppName, controllers.Version, controllers.WorkerImage, setupLog)
+	telemetryConfig := telemetry.NewConfig(controllers.AppName, controllers.Version, workerImage, setupLog)
 	telemetryClient, err := telemetry.NewClient(telemetryConfig)
 	if err != nil {
 		setupLog.Error(err, "unable to create telemetry client")
@@ -106,6 +166,17 @@ func main() {
 		setupLog.Error(err, "unable to create controller", "controller", "LoadTest")
 		os.Exit(1)
 	}
+	if err = (&controllers.LoadTestScheduleReconciler{
+		Client:   mgr.GetClient(),
+		Scheme:   mgr.GetScheme(),
+		Recorder: mgr.GetEventRecorderFor("loadtestschedule-controller"),
+	}).SetupWithManager(mgr); err != nil {
+		setupLog.Error(err, "unable to create controller", "controller", "LoadTestSchedule")
+		os.Exit(1)
+	}
+	if enableWebhooks {
+		controllers.SetupWebhookWithManager(mgr)
+	}
 	// +kubebuilder:scaffold:builder
 
 	if err := mgr.AddHealthzCheck("healthz", healthz.Ping); err != nil {